@@ -2,18 +2,40 @@ package multi
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/mingzhi/biogo/seq"
 	"github.com/mingzhi/ncbiftp/seqrecord"
 	"io"
-	"os/exec"
 	"strings"
 )
 
+// AlignFunc aligns sequences in fasta format from stdin,
+// and writes the alignment in fasta format to stdout.
 type AlignFunc func(stdin io.Reader, stdout, stderr io.Writer, options ...string) error
 
+// runAlign runs the aligner and reads the aligned sequences,
+// returning errors with the stderr of the aligner.
+func runAlign(stdin io.Reader, alignFunc AlignFunc, options ...string) ([]*seq.Sequence, error) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	if err := alignFunc(stdin, stdout, stderr, options...); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("empty alignment: %s", strings.TrimSpace(stderr.String()))
+	}
+	fr := seq.NewFastaReader(stdout)
+	alns, err := fr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading alignment: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return alns, nil
+}
+
 // Multiple sequence alignment of protein sequences
-// and back translate them to nucleotide sequences
-func AlignProt(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ...string) []seqrecord.SeqRecord {
+// and back translate them to nucleotide sequences,
+// which should be checked by ValidateRecords.
+func AlignProt(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ...string) ([]seqrecord.SeqRecord, error) {
 	// prepare protein sequences in fasta format
 	stdin := new(bytes.Buffer)
 	srMap := make(map[string]seqrecord.SeqRecord)
@@ -23,20 +45,19 @@ func AlignProt(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ..
 		stdin.WriteString("\n")
 		srMap[sr.Id+"|"+sr.Genome] = sr
 	}
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	alignFunc(stdin, stdout, stderr, options...)
-	fr := seq.NewFastaReader(stdout)
-	alns, err := fr.ReadAll()
+	alns, err := runAlign(stdin, alignFunc, options...)
 	if err != nil {
-		panic(string(stderr.Bytes()))
+		return nil, err
 	}
 
 	alnSeqRecords := []seqrecord.SeqRecord{}
 	for _, aaSeq := range alns {
 		if aaSeq != nil {
 			if _, found := srMap[aaSeq.Id]; found {
-				na := BackTranslate(aaSeq.Seq, srMap[aaSeq.Id].Nucl)
+				na, err := BackTranslate(aaSeq.Seq, srMap[aaSeq.Id].Nucl)
+				if err != nil {
+					return nil, fmt.Errorf("back translating %s: %v", aaSeq.Id, err)
+				}
 				sr := seqrecord.SeqRecord{
 					Id:     strings.Split(aaSeq.Id, "|")[0],
 					Prot:   aaSeq.Seq,
@@ -51,13 +72,12 @@ func AlignProt(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ..
 		}
 	}
 
-	return alnSeqRecords
-
+	return alnSeqRecords, nil
 }
 
 // Multiple sequence alignment of protein sequences
 // and back translate them to nucleotide sequences
-func AlignNucl(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ...string) []seqrecord.SeqRecord {
+func AlignNucl(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ...string) ([]seqrecord.SeqRecord, error) {
 	// prepare protein sequences in fasta format
 	stdin := new(bytes.Buffer)
 	srMap := make(map[string]seqrecord.SeqRecord)
@@ -67,13 +87,9 @@ func AlignNucl(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ..
 		stdin.WriteString("\n")
 		srMap[sr.Id+"|"+sr.Genome] = sr
 	}
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	alignFunc(stdin, stdout, stderr, options...)
-	fr := seq.NewFastaReader(stdout)
-	alns, err := fr.ReadAll()
+	alns, err := runAlign(stdin, alignFunc, options...)
 	if err != nil {
-		panic(string(stderr.Bytes()))
+		return nil, err
 	}
 
 	alnSeqRecords := []seqrecord.SeqRecord{}
@@ -94,31 +110,27 @@ func AlignNucl(seqRecords []seqrecord.SeqRecord, alignFunc AlignFunc, options ..
 		}
 	}
 
-	return alnSeqRecords
-
-}
-
-// do multiple sequence alignment using muscle
-func Muscle(stdin io.Reader, stdout, stderr io.Writer, options ...string) (err error) {
-	cmd := exec.Command("muscle", options...)
-	cmd.Stdin = stdin
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	err = cmd.Run()
-	return
+	return alnSeqRecords, nil
 }
 
-// back translate amino acid alignment to nucleotide sequences.
-func BackTranslate(aa, na []byte) []byte {
+// back translate amino acid alignment to nucleotide sequences,
+// which should have exactly three nucleotides for each amino acid.
+func BackTranslate(aa, na []byte) ([]byte, error) {
 	k := 0
 	aln := []byte{}
 	for i := 0; i < len(aa); i++ {
 		if aa[i] == '-' {
 			aln = append(aln, []byte{'-', '-', '-'}...)
 		} else {
+			if (k+1)*3 > len(na) {
+				return nil, fmt.Errorf("%d nucleotides for more than %d amino acids", len(na), k)
+			}
 			aln = append(aln, na[k*3:(k+1)*3]...)
 			k++
 		}
 	}
-	return aln
+	if k*3 != len(na) {
+		return nil, fmt.Errorf("%d nucleotides for %d amino acids", len(na), k)
+	}
+	return aln, nil
 }
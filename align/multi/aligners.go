@@ -0,0 +1,153 @@
+package multi
+
+// External multiple sequence aligners.
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// binaries of aligners by names.
+var alignerBinaries = map[string]string{
+	"":         "muscle",
+	"muscle":   "muscle",
+	"muscle5":  "muscle",
+	"mafft":    "mafft",
+	"clustalo": "clustalo",
+	"prank":    "prank",
+}
+
+// Available returns whether the aligner can be run,
+// which is always true for the builtin aligner.
+func Available(name string) bool {
+	binary, found := alignerBinaries[name]
+	if !found {
+		return name == "builtin"
+	}
+	_, err := exec.LookPath(binary)
+	return err == nil
+}
+
+// NewAlignFunc returns an AlignFunc by the name of the program:
+// muscle (v3), muscle5, mafft, clustalo, prank,
+// or builtin for Progressive.
+func NewAlignFunc(name string) (AlignFunc, error) {
+	switch name {
+	case "builtin":
+		return Progressive, nil
+	case "", "muscle":
+		return Muscle, nil
+	case "muscle5":
+		return Muscle5, nil
+	case "mafft":
+		return Mafft, nil
+	case "clustalo":
+		return ClustalOmega, nil
+	case "prank":
+		return Prank, nil
+	}
+	return nil, fmt.Errorf("unknown multiple aligner: %s", name)
+}
+
+// do multiple sequence alignment using muscle (v3),
+// which reads fasta from stdin and writes to stdout.
+func Muscle(stdin io.Reader, stdout, stderr io.Writer, options ...string) (err error) {
+	cmd := exec.Command("muscle", options...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	return
+}
+
+// Muscle5 aligns with MUSCLE 5, muscle -align in -output out.
+func Muscle5(stdin io.Reader, stdout, stderr io.Writer, options ...string) error {
+	return withInputFile(stdin, func(in, dir string) error {
+		out := filepath.Join(dir, "out.fasta")
+		args := append([]string{"-align", in, "-output", out}, options...)
+		if err := runCommand(exec.Command("muscle", args...), nil, stderr); err != nil {
+			return err
+		}
+		return copyFile(stdout, out)
+	})
+}
+
+// Mafft aligns with MAFFT, mafft --auto --quiet in.
+func Mafft(stdin io.Reader, stdout, stderr io.Writer, options ...string) error {
+	return withInputFile(stdin, func(in, dir string) error {
+		args := append([]string{"--auto", "--quiet"}, options...)
+		return runCommand(exec.Command("mafft", append(args, in)...), stdout, stderr)
+	})
+}
+
+// ClustalOmega aligns with Clustal Omega, clustalo -i in -o out --outfmt=fa.
+func ClustalOmega(stdin io.Reader, stdout, stderr io.Writer, options ...string) error {
+	return withInputFile(stdin, func(in, dir string) error {
+		out := filepath.Join(dir, "out.fasta")
+		args := append([]string{"-i", in, "-o", out, "--outfmt=fa", "--force"}, options...)
+		if err := runCommand(exec.Command("clustalo", args...), nil, stderr); err != nil {
+			return err
+		}
+		return copyFile(stdout, out)
+	})
+}
+
+// Prank aligns with PRANK, prank -d=in -o=out,
+// which writes the alignment into out.best.fas.
+func Prank(stdin io.Reader, stdout, stderr io.Writer, options ...string) error {
+	return withInputFile(stdin, func(in, dir string) error {
+		out := filepath.Join(dir, "out")
+		args := append([]string{"-d=" + in, "-o=" + out, "-quiet"}, options...)
+		if err := runCommand(exec.Command("prank", args...), nil, stderr); err != nil {
+			return err
+		}
+		return copyFile(stdout, out+".best.fas")
+	})
+}
+
+// withInputFile writes stdin into a file in a temporary folder,
+// for aligners reading files, and removes the folder afterwards.
+func withInputFile(stdin io.Reader, run func(in, dir string) error) error {
+	dir, err := ioutil.TempDir(os.TempDir(), "multi")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.fasta")
+	f, err := os.Create(in)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, stdin)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return run(in, dir)
+}
+
+// runCommand runs the command with stdout and stderr.
+func runCommand(cmd *exec.Cmd, stdout, stderr io.Writer) error {
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// copyFile copies the content of the file into w.
+func copyFile(w io.Writer, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,111 @@
+package multi
+
+// Validation of coding sequences against their protein sequences.
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"github.com/mingzhi/ncbiftp/taxonomy"
+)
+
+// standard stop codons, used without a genetic code.
+var stopCodons = map[string]bool{"TAA": true, "TAG": true, "TGA": true}
+
+// CheckCoding checks that the coding sequence encodes the protein
+// by the genetic code, and returns the coding sequence
+// with a trailing stop codon or a partial codon trimmed,
+// and whether it has been repaired by trimming a partial codon.
+// A single trailing stop codon, included in CDS coordinates,
+// is trimmed without being a repair.
+// Gaps and a trailing stop of the protein are ignored.
+// Translations are not checked without a genetic code.
+func CheckCoding(prot, nucl []byte, gc *taxonomy.GeneticCode) (cds []byte, repaired bool, err error) {
+	p := bytes.Replace(prot, []byte{'-'}, nil, -1)
+	p = bytes.TrimRight(p, "*")
+	n := len(p)
+
+	cds = nucl
+	if extra := len(cds) - 3*n; extra > 0 && extra < 6 {
+		if extra >= 3 && !isStop(cds[3*n:3*n+3], gc) {
+			return nil, false, fmt.Errorf("frameshift: %d nucleotides for %d amino acids", len(nucl), n)
+		}
+		cds = cds[:3*n]
+		repaired = extra != 3
+	}
+	if len(cds) != 3*n {
+		return nil, false, fmt.Errorf("frameshift: %d nucleotides for %d amino acids", len(nucl), n)
+	}
+	if gc == nil {
+		return
+	}
+
+	mismatches, first := 0, -1
+	for i := 0; i < n; i++ {
+		codon := string(bytes.ToUpper(cds[3*i : 3*i+3]))
+		aa, found := gc.Table[codon]
+		if !found {
+			// ambiguous codons.
+			continue
+		}
+		if aa == '*' {
+			// selenocysteine and pyrrolysine are encoded by stop codons.
+			if p[i] == 'U' || p[i] == 'O' {
+				continue
+			}
+			return nil, false, fmt.Errorf("internal stop codon %s at codon %d", codon, i+1)
+		}
+		// alternative start codons are translated to M.
+		if aa == p[i] || p[i] == 'X' || (i == 0 && p[i] == 'M') {
+			continue
+		}
+		mismatches++
+		if first < 0 {
+			first = i
+		}
+	}
+	if mismatches > 0 {
+		return nil, false, fmt.Errorf("%d codons not translated to the protein, first at codon %d", mismatches, first+1)
+	}
+	return
+}
+
+// isStop returns whether the codon is a stop codon.
+func isStop(codon []byte, gc *taxonomy.GeneticCode) bool {
+	c := string(bytes.ToUpper(codon))
+	if gc != nil {
+		if aa, found := gc.Table[c]; found {
+			return aa == '*'
+		}
+	}
+	return stopCodons[c]
+}
+
+// CodingProblem is a problem of a record found by ValidateRecords.
+type CodingProblem struct {
+	Id       string
+	Genome   string
+	Repaired bool // repaired, or dropped.
+	Err      error
+}
+
+// ValidateRecords checks coding sequences of records
+// by the genetic codes of records, and returns records
+// which are valid or repaired, and problems of each record.
+func ValidateRecords(records []seqrecord.SeqRecord) (valid []seqrecord.SeqRecord, problems []CodingProblem) {
+	gcMap := taxonomy.GeneticCodes()
+	for _, r := range records {
+		cds, repaired, err := CheckCoding(r.Prot, r.Nucl, gcMap[r.Code])
+		if err != nil {
+			problems = append(problems, CodingProblem{Id: r.Id, Genome: r.Genome, Err: err})
+			continue
+		}
+		if repaired {
+			err := fmt.Errorf("trimmed %d nucleotides at the end", len(r.Nucl)-len(cds))
+			problems = append(problems, CodingProblem{Id: r.Id, Genome: r.Genome, Repaired: true, Err: err})
+		}
+		r.Nucl = cds
+		valid = append(valid, r)
+	}
+	return
+}
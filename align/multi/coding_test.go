@@ -0,0 +1,74 @@
+package multi
+
+import (
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"github.com/mingzhi/ncbiftp/taxonomy"
+	"testing"
+)
+
+// standardCode returns the standard genetic code.
+func standardCode() *taxonomy.GeneticCode {
+	bases := "TCAG"
+	aas := "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"
+	gc := &taxonomy.GeneticCode{Id: "1", Table: make(map[string]byte)}
+	for i := 0; i < 64; i++ {
+		codon := string([]byte{bases[i/16], bases[i/4%4], bases[i%4]})
+		gc.Table[codon] = aas[i]
+	}
+	return gc
+}
+
+func TestCheckCoding(t *testing.T) {
+	gc := standardCode()
+	cases := []struct {
+		prot, nucl string
+		cds        string
+		repaired   bool
+		valid      bool
+	}{
+		{"MKL", "ATGAAACTG", "ATGAAACTG", false, true},
+		{"MKL", "ATGAAACTGTAA", "ATGAAACTG", false, true},
+		{"MKL", "ATGAAACTGTAAT", "ATGAAACTG", true, true},
+		{"MKL", "ATGAAACTGTA", "ATGAAACTG", true, true},
+		{"M-KL", "GTGAAACTG", "GTGAAACTG", false, true},
+		{"MKL", "ATGAAACTGAAA", "", false, false},
+		{"MKL", "ATGAAACT", "", false, false},
+		{"MKL", "ATGTAACTG", "", false, false},
+		{"MKL", "ATGAAATTT", "", false, false},
+	}
+	for _, c := range cases {
+		cds, repaired, err := CheckCoding([]byte(c.prot), []byte(c.nucl), gc)
+		if (err == nil) != c.valid {
+			t.Errorf("%s %s: got error %v, want valid %v\n", c.prot, c.nucl, err, c.valid)
+			continue
+		}
+		if string(cds) != c.cds || repaired != c.repaired {
+			t.Errorf("%s %s: got %s repaired %v, want %s repaired %v\n", c.prot, c.nucl, cds, repaired, c.cds, c.repaired)
+		}
+	}
+}
+
+func TestValidateRecordsStopCodon(t *testing.T) {
+	records := []seqrecord.SeqRecord{
+		{Id: "1", Genome: "NC_000001", Prot: []byte("MKL"), Nucl: []byte("ATGAAACTGTAA"), Code: "11"},
+		{Id: "2", Genome: "NC_000001", Prot: []byte("MKL"), Nucl: []byte("ATGAAACTGTA"), Code: "11"},
+	}
+	valid, problems := ValidateRecords(records)
+	if len(valid) != 2 || string(valid[0].Nucl) != "ATGAAACTG" {
+		t.Errorf("valid records: got %v\n", valid)
+	}
+	// the trailing stop codon is not a problem, but the partial codon is.
+	if len(problems) != 1 || problems[0].Id != "2" || !problems[0].Repaired {
+		t.Errorf("problems: got %v, want one repaired record 2\n", problems)
+	}
+}
+
+func TestBackTranslate(t *testing.T) {
+	aln, err := BackTranslate([]byte("M-K"), []byte("ATGAAA"))
+	if err != nil || string(aln) != "ATG---AAA" {
+		t.Errorf("got %s %v, want ATG---AAA\n", aln, err)
+	}
+	if _, err := BackTranslate([]byte("MKL"), []byte("ATGAAA")); err == nil {
+		t.Errorf("no error for a short nucleotide sequence\n")
+	}
+}
@@ -0,0 +1,335 @@
+package multi
+
+// A pure-Go progressive multiple aligner.
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mingzhi/biogo/seq"
+	"io"
+	"math"
+	"strings"
+)
+
+// Progressive aligns sequences without external programs.
+// It merges sequences by UPGMA of k-mer distances,
+// aligning profiles by Needleman-Wunsch with affine gaps,
+// scored by BLOSUM62 for proteins or a nucleotide matrix.
+// Sequences are written in the input order, and ties are broken
+// by the input order, so that results are deterministic.
+//
+// options: -type=prot or -type=nucl, detected from sequences by default.
+func Progressive(stdin io.Reader, stdout, stderr io.Writer, options ...string) error {
+	seqType := ""
+	for _, opt := range options {
+		switch opt {
+		case "-type=prot", "-type=nucl":
+			seqType = strings.TrimPrefix(opt, "-type=")
+		default:
+			return fmt.Errorf("unknown option of the progressive aligner: %s", opt)
+		}
+	}
+
+	records, err := seq.NewFastaReader(stdin).ReadAll()
+	if err != nil {
+		return err
+	}
+	ids := []string{}
+	seqs := [][]byte{}
+	for _, r := range records {
+		if r != nil {
+			ids = append(ids, r.Id)
+			seqs = append(seqs, ungap(r.Seq))
+		}
+	}
+	if len(seqs) == 0 {
+		return fmt.Errorf("no sequences to align")
+	}
+
+	sc, k := blosum62, 3
+	if seqType == "nucl" || (seqType == "" && isNucleotide(seqs)) {
+		sc, k = nucleotideScoring, 8
+	}
+	aln := progressiveAlign(seqs, sc, k)
+
+	w := bufio.NewWriter(stdout)
+	for i, id := range ids {
+		fmt.Fprintf(w, ">%s\n%s\n", id, aln[i])
+	}
+	return w.Flush()
+}
+
+// ungap returns the sequence without gaps.
+func ungap(s []byte) []byte {
+	u := []byte{}
+	for _, b := range s {
+		if b != '-' && b != '.' {
+			u = append(u, b)
+		}
+	}
+	return u
+}
+
+// progressiveAlign returns aligned sequences, in the order of seqs.
+func progressiveAlign(seqs [][]byte, sc *scoring, k int) [][]byte {
+	n := len(seqs)
+	dist := kmerDistances(seqs, k)
+
+	// clusters being merged, by UPGMA.
+	profiles := make([]*profile, n)
+	for i, s := range seqs {
+		profiles[i] = newProfile(i, s, sc)
+	}
+	active := make([]bool, n)
+	for i := range active {
+		active[i] = true
+	}
+	for merged := 1; merged < n; merged++ {
+		a, b := -1, -1
+		for i := 0; i < n; i++ {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < n; j++ {
+				if active[j] && (a < 0 || dist[i][j] < dist[a][b]) {
+					a, b = i, j
+				}
+			}
+		}
+
+		na, nb := float64(len(profiles[a].members)), float64(len(profiles[b].members))
+		for i := 0; i < n; i++ {
+			if active[i] && i != a && i != b {
+				d := (na*dist[a][i] + nb*dist[b][i]) / (na + nb)
+				dist[a][i], dist[i][a] = d, d
+			}
+		}
+		profiles[a] = alignProfiles(profiles[a], profiles[b], sc)
+		profiles[b] = nil
+		active[b] = false
+	}
+
+	p := profiles[0]
+	aln := make([][]byte, n)
+	for r, i := range p.members {
+		aln[i] = p.rows[r]
+	}
+	return aln
+}
+
+// kmerDistances returns distances of sequences,
+// as fractions of k-mers of the shorter sequence not shared.
+func kmerDistances(seqs [][]byte, k int) [][]float64 {
+	counts := make([]map[string]int, len(seqs))
+	for i, s := range seqs {
+		counts[i] = make(map[string]int)
+		u := strings.ToUpper(string(s))
+		for j := 0; j+k <= len(u); j++ {
+			counts[i][u[j:j+k]]++
+		}
+	}
+
+	dist := make([][]float64, len(seqs))
+	for i := range dist {
+		dist[i] = make([]float64, len(seqs))
+	}
+	for i := range seqs {
+		for j := i + 1; j < len(seqs); j++ {
+			total := minInt(len(seqs[i]), len(seqs[j])) - k + 1
+			d := 1.0
+			if total > 0 {
+				shared := 0
+				for kmer, c := range counts[i] {
+					shared += minInt(c, counts[j][kmer])
+				}
+				d = 1 - float64(shared)/float64(total)
+			}
+			dist[i][j], dist[j][i] = d, d
+		}
+	}
+	return dist
+}
+
+// profile is a group of aligned sequences.
+type profile struct {
+	members []int    // indices of sequences.
+	rows    [][]byte // aligned sequences.
+	freqs   []column // residue frequencies of columns.
+}
+
+// column has frequencies of residues in a profile column,
+// which sum to the fraction of sequences without gaps.
+type column struct {
+	residues []int
+	freqs    []float64
+}
+
+func newProfile(index int, s []byte, sc *scoring) *profile {
+	p := &profile{members: []int{index}, rows: [][]byte{s}}
+	p.update(sc)
+	return p
+}
+
+// update computes column frequencies from rows.
+func (p *profile) update(sc *scoring) {
+	length := 0
+	if len(p.rows) > 0 {
+		length = len(p.rows[0])
+	}
+	p.freqs = make([]column, length)
+	w := 1 / float64(len(p.rows))
+	for j := 0; j < length; j++ {
+		c := &p.freqs[j]
+		for _, row := range p.rows {
+			if row[j] == '-' {
+				continue
+			}
+			r := sc.index[row[j]]
+			found := false
+			for x, res := range c.residues {
+				if res == r {
+					c.freqs[x] += w
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.residues = append(c.residues, r)
+				c.freqs = append(c.freqs, w)
+			}
+		}
+	}
+}
+
+// states of the alignment of profiles.
+const (
+	stateMatch = iota // columns of the two profiles aligned.
+	stateGapB         // a column of the first profile against gaps.
+	stateGapA         // gaps against a column of the second profile.
+)
+
+// alignProfiles aligns two profiles by Needleman-Wunsch
+// with affine gaps (Gotoh), and returns the merged profile.
+func alignProfiles(a, b *profile, sc *scoring) *profile {
+	n, m := len(a.freqs), len(b.freqs)
+	// scores of residues against each column of b.
+	bScores := make([][]float64, m)
+	for j, c := range b.freqs {
+		bScores[j] = make([]float64, len(sc.alphabet))
+		for x := range bScores[j] {
+			for y, r := range c.residues {
+				bScores[j][x] += c.freqs[y] * sc.matrix[x][r]
+			}
+		}
+	}
+
+	inf := math.Inf(-1)
+	// scores of the previous and current rows of three states.
+	prev := [3][]float64{make([]float64, m+1), make([]float64, m+1), make([]float64, m+1)}
+	cur := [3][]float64{make([]float64, m+1), make([]float64, m+1), make([]float64, m+1)}
+	// traceback of previous states of each state.
+	trace := [3][][]byte{}
+	for s := range trace {
+		trace[s] = make([][]byte, n+1)
+		for i := range trace[s] {
+			trace[s][i] = make([]byte, m+1)
+		}
+	}
+
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= m; j++ {
+			if i == 0 && j == 0 {
+				cur[stateMatch][0], cur[stateGapB][0], cur[stateGapA][0] = 0, inf, inf
+				continue
+			}
+			// match state.
+			cur[stateMatch][j] = inf
+			if i > 0 && j > 0 {
+				s, from := best3(prev[stateMatch][j-1], prev[stateGapB][j-1], prev[stateGapA][j-1])
+				c := a.freqs[i-1]
+				for x, r := range c.residues {
+					s += c.freqs[x] * bScores[j-1][r]
+				}
+				cur[stateMatch][j] = s
+				trace[stateMatch][i][j] = from
+			}
+			// a column of a against gaps.
+			cur[stateGapB][j] = inf
+			if i > 0 {
+				s, from := best3(prev[stateMatch][j]-sc.gapOpen, prev[stateGapB][j]-sc.gapExtend, prev[stateGapA][j]-sc.gapOpen)
+				cur[stateGapB][j] = s
+				trace[stateGapB][i][j] = from
+			}
+			// gaps against a column of b.
+			cur[stateGapA][j] = inf
+			if j > 0 {
+				s, from := best3(cur[stateMatch][j-1]-sc.gapOpen, cur[stateGapB][j-1]-sc.gapOpen, cur[stateGapA][j-1]-sc.gapExtend)
+				cur[stateGapA][j] = s
+				trace[stateGapA][i][j] = from
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	// trace back states from the end.
+	_, state := best3(prev[stateMatch][m], prev[stateGapB][m], prev[stateGapA][m])
+	states := []byte{}
+	for i, j := n, m; i > 0 || j > 0; {
+		states = append(states, state)
+		from := trace[state][i][j]
+		switch state {
+		case stateMatch:
+			i, j = i-1, j-1
+		case stateGapB:
+			i--
+		case stateGapA:
+			j--
+		}
+		state = from
+	}
+
+	merged := &profile{members: append(append([]int{}, a.members...), b.members...)}
+	for _, row := range a.rows {
+		merged.rows = append(merged.rows, applyStates(row, states, stateGapA))
+	}
+	for _, row := range b.rows {
+		merged.rows = append(merged.rows, applyStates(row, states, stateGapB))
+	}
+	merged.update(sc)
+	return merged
+}
+
+// applyStates returns the row with gaps inserted at the gap state,
+// following states in the reverse order.
+func applyStates(row []byte, states []byte, gap byte) []byte {
+	aligned := make([]byte, 0, len(states))
+	k := 0
+	for x := len(states) - 1; x >= 0; x-- {
+		if states[x] == gap {
+			aligned = append(aligned, '-')
+		} else {
+			aligned = append(aligned, row[k])
+			k++
+		}
+	}
+	return aligned
+}
+
+// best3 returns the max of scores of three states and its state,
+// preferring the earlier state among equals.
+func best3(match, gapB, gapA float64) (float64, byte) {
+	if match >= gapB && match >= gapA {
+		return match, stateMatch
+	}
+	if gapB >= gapA {
+		return gapB, stateGapB
+	}
+	return gapA, stateGapA
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package multi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBlosum62(t *testing.T) {
+	for _, sc := range []*scoring{blosum62, nucleotideScoring} {
+		n := len(sc.alphabet)
+		for i := 0; i < n; i++ {
+			if len(sc.matrix[i]) != n {
+				t.Fatalf("row %c: got %d scores, want %d\n", sc.alphabet[i], len(sc.matrix[i]), n)
+			}
+			for j := 0; j < n; j++ {
+				if sc.matrix[i][j] != sc.matrix[j][i] {
+					t.Errorf("matrix is not symmetric at %c%c\n", sc.alphabet[i], sc.alphabet[j])
+				}
+			}
+		}
+	}
+	if s := blosum62.matrix[blosum62.index['W']][blosum62.index['W']]; s != 11 {
+		t.Errorf("WW: got %g, want 11\n", s)
+	}
+}
+
+func runProgressive(t *testing.T, input string, options ...string) string {
+	stdout := new(bytes.Buffer)
+	if err := Progressive(strings.NewReader(input), stdout, new(bytes.Buffer), options...); err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String()
+}
+
+func TestProgressive(t *testing.T) {
+	input := ">a\nMKVLAAGIVGLLLAQWERTY\n" +
+		">b\nMKVLAAGIVGLLLAQWERTY\n" +
+		">c\nMKVLAGIVGLLLAQWERTY\n" +
+		">d\nMKVLAAGIVGLLMAQWERTY\n"
+	// the deletion in the repeat AA is placed at the left.
+	expected := ">a\nMKVLAAGIVGLLLAQWERTY\n" +
+		">b\nMKVLAAGIVGLLLAQWERTY\n" +
+		">c\nMKVL-AGIVGLLLAQWERTY\n" +
+		">d\nMKVLAAGIVGLLMAQWERTY\n"
+	got := runProgressive(t, input)
+	if got != expected {
+		t.Errorf("alignment: got\n%s\nwant\n%s\n", got, expected)
+	}
+	// deterministic results.
+	if runProgressive(t, input) != got {
+		t.Errorf("alignments differ between runs\n")
+	}
+}
+
+func TestProgressiveNucl(t *testing.T) {
+	input := ">a\nATGAAACTGGTTACCGGTAAA\n" +
+		">b\nATGAAACTGACCGGTAAA\n" +
+		">c\nATGAAACTGGTTACCGGTAAA\n"
+	got := runProgressive(t, input)
+	expected := ">a\nATGAAACTGGTTACCGGTAAA\n" +
+		">b\nATGAAACTG---ACCGGTAAA\n" +
+		">c\nATGAAACTGGTTACCGGTAAA\n"
+	if got != expected {
+		t.Errorf("alignment: got\n%s\nwant\n%s\n", got, expected)
+	}
+	if _, err := NewAlignFunc("builtin"); err != nil {
+		t.Error(err)
+	}
+}
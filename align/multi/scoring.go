@@ -0,0 +1,100 @@
+package multi
+
+// Substitution matrices of the progressive aligner.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// scoring is a substitution matrix with affine gap penalties.
+type scoring struct {
+	alphabet  string
+	index     [256]int // index of letters in the alphabet, unknown letters to the last one.
+	matrix    [][]float64
+	gapOpen   float64 // penalty of the first position of a gap.
+	gapExtend float64 // penalty of each following position.
+}
+
+// newScoring parses a matrix of rows of letters and scores.
+func newScoring(alphabet, rows string, gapOpen, gapExtend float64) *scoring {
+	s := &scoring{alphabet: alphabet, gapOpen: gapOpen, gapExtend: gapExtend}
+	for i := range s.index {
+		s.index[i] = len(alphabet) - 1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		s.index[alphabet[i]] = i
+		s.index[strings.ToLower(alphabet[i : i+1])[0]] = i
+	}
+	for _, line := range strings.Split(strings.TrimSpace(rows), "\n") {
+		row := []float64{}
+		for _, field := range strings.Fields(line)[1:] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				panic(err)
+			}
+			row = append(row, v)
+		}
+		s.matrix = append(s.matrix, row)
+	}
+	return s
+}
+
+// blosum62 scores amino acids, with unknown letters as X.
+var blosum62 = newScoring("ARNDCQEGHILKMFPSTWYVBZ*X", `
+A  4 -1 -2 -2  0 -1 -1  0 -2 -1 -1 -1 -1 -2 -1  1  0 -3 -2  0 -2 -1 -4  0
+R -1  5  0 -2 -3  1  0 -2  0 -3 -2  2 -1 -3 -2 -1 -1 -3 -2 -3 -1  0 -4 -1
+N -2  0  6  1 -3  0  0  0  1 -3 -3  0 -2 -3 -2  1  0 -4 -2 -3  3  0 -4 -1
+D -2 -2  1  6 -3  0  2 -1 -1 -3 -4 -1 -3 -3 -1  0 -1 -4 -3 -3  4  1 -4 -1
+C  0 -3 -3 -3  9 -3 -4 -3 -3 -1 -1 -3 -1 -2 -3 -1 -1 -2 -2 -1 -3 -3 -4 -2
+Q -1  1  0  0 -3  5  2 -2  0 -3 -2  1  0 -3 -1  0 -1 -2 -1 -2  0  3 -4 -1
+E -1  0  0  2 -4  2  5 -2  0 -3 -3  1 -2 -3 -1  0 -1 -3 -2 -2  1  4 -4 -1
+G  0 -2  0 -1 -3 -2 -2  6 -2 -4 -4 -2 -3 -3 -2  0 -2 -2 -3 -3 -1 -2 -4 -1
+H -2  0  1 -1 -3  0  0 -2  8 -3 -3 -1 -2 -1 -2 -1 -2 -2  2 -3  0  0 -4 -1
+I -1 -3 -3 -3 -1 -3 -3 -4 -3  4  2 -3  1  0 -3 -2 -1 -3 -1  3 -3 -3 -4 -1
+L -1 -2 -3 -4 -1 -2 -3 -4 -3  2  4 -2  2  0 -3 -2 -1 -2 -1  1 -4 -3 -4 -1
+K -1  2  0 -1 -3  1  1 -2 -1 -3 -2  5 -1 -3 -1  0 -1 -3 -2 -2  0  1 -4 -1
+M -1 -1 -2 -3 -1  0 -2 -3 -2  1  2 -1  5  0 -2 -1 -1 -1 -1  1 -3 -1 -4 -1
+F -2 -3 -3 -3 -2 -3 -3 -3 -1  0  0 -3  0  6 -4 -2 -2  1  3 -1 -3 -3 -4 -1
+P -1 -2 -2 -1 -3 -1 -1 -2 -2 -3 -3 -1 -2 -4  7 -1 -1 -4 -3 -2 -2 -1 -4 -2
+S  1 -1  1  0 -1  0  0  0 -1 -2 -2  0 -1 -2 -1  4  1 -3 -2 -2  0  0 -4  0
+T  0 -1  0 -1 -1 -1 -1 -2 -2 -1 -1 -1 -1 -2 -1  1  5 -2 -2  0 -1 -1 -4  0
+W -3 -3 -4 -4 -2 -2 -3 -2 -2 -3 -2 -3 -1  1 -4 -3 -2 11  2 -3 -4 -3 -4 -2
+Y -2 -2 -2 -3 -2 -1 -2 -3  2 -1 -1 -2 -1  3 -3 -2 -2  2  7 -1 -3 -2 -4 -1
+V  0 -3 -3 -3 -1 -2 -2 -3 -3  3  1 -2  1 -1 -2 -2  0 -3 -1  4 -3 -2 -4 -1
+B -2 -1  3  4 -3  0  1 -1  0 -3 -4  0 -3 -3 -2  0 -1 -4 -3 -3  4  1 -4 -1
+Z -1  0  0  1 -3  3  4 -2  0 -3 -3  1 -1 -3 -1  0 -1 -3 -2 -2  1  4 -4 -1
+* -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4  1 -4
+X  0 -1 -1 -1 -2 -1 -1 -1 -1 -1 -1 -1 -1 -1 -2  0  0 -2 -1 -1 -1 -1 -4 -1
+`, 11, 1)
+
+// nucleotideScoring scores nucleotides, with U as T and unknown letters as N.
+var nucleotideScoring = func() *scoring {
+	s := newScoring("ACGTN", `
+A  5 -4 -4 -4 -2
+C -4  5 -4 -4 -2
+G -4 -4  5 -4 -2
+T -4 -4 -4  5 -2
+N -2 -2 -2 -2 -1
+`, 10, 1)
+	s.index['U'] = s.index['T']
+	s.index['u'] = s.index['T']
+	return s
+}()
+
+// isNucleotide returns whether most letters of sequences are nucleotides.
+func isNucleotide(seqs [][]byte) bool {
+	total, nucl := 0, 0
+	for _, s := range seqs {
+		for _, b := range s {
+			if b == '-' || b == '.' {
+				continue
+			}
+			total++
+			if strings.IndexByte("ACGTUNacgtun", b) >= 0 {
+				nucl++
+			}
+		}
+	}
+	return total > 0 && float64(nucl) >= 0.9*float64(total)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/mingzhi/biogo/seq"
 	"github.com/mingzhi/gomath/stat/correlation"
 	"github.com/mingzhi/gomath/stat/desc/meanvar"
+	"github.com/mingzhi/meta/cov"
 	"github.com/mingzhi/ncbiftp/genomes/profiling"
 	"github.com/mingzhi/ncbiftp/taxonomy"
 	"io"
@@ -38,6 +39,8 @@ func (m MappedRead) Len() int {
 var MINBQ int
 var MINMQ int
 var SAMPLES int
+var QUALWEIGHTED bool
+var SUBPRIOR float64
 
 func main() {
 	// Command variables.
@@ -57,6 +60,8 @@ func main() {
 	flag.IntVar(&MINBQ, "min-bq", 13, "min base quality")
 	flag.IntVar(&MINMQ, "min-mq", 0, "min map quality")
 	flag.IntVar(&SAMPLES, "samples", 100, "number of samples")
+	flag.BoolVar(&QUALWEIGHTED, "qual-weighted", false, "use posterior probabilities of differences instead of min-bq")
+	flag.Float64Var(&SUBPRIOR, "sub-prior", cov.DefaultSubPrior, "prior probability of a true difference")
 	flag.Parse()
 	// Print usage if the number of arguments is not satisfied.
 	if flag.NArg() < 4 {
@@ -151,6 +156,7 @@ func slideReads(readChan chan *sam.Record) chan SubProfile {
 
 // compareMappedReads compares two MappedReads in their overlapped part,
 // and return a subsitution profile.
+// With QUALWEIGHTED, each site is the posterior probability of a true difference.
 func compareMappedReads(a, b MappedRead) SubProfile {
 	var subs []float64
 	lag := b.Pos - a.Pos
@@ -158,7 +164,11 @@ func compareMappedReads(a, b MappedRead) SubProfile {
 		i := j + lag
 		d := math.NaN()
 		if isATGC(a.Seq[i]) && isATGC(b.Seq[j]) {
-			if int(a.Qual[i]) > MINBQ && int(b.Qual[j]) > MINBQ {
+			if QUALWEIGHTED {
+				e1 := cov.PhredErr(a.Qual[i])
+				e2 := cov.PhredErr(b.Qual[j])
+				d = cov.DiffProb(a.Seq[i] != b.Seq[j], e1, e2, SUBPRIOR)
+			} else if int(a.Qual[i]) > MINBQ && int(b.Qual[j]) > MINBQ {
 				if a.Seq[i] != b.Seq[j] {
 					d = 1.0
 				} else {
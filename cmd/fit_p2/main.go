@@ -0,0 +1,358 @@
+// Fit correlation functions (P2 and P4) from meta_p2 or collect_genes results.
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mingzhi/meta/fit"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// CorrResult contains a correlation result,
+// a row of the l,m,v,n,t,b (or g) table,
+// in which lags l are in bp, and values of types other than Ks
+// are normalized by Ks.
+type CorrResult struct {
+	Lag      int
+	Value    float64
+	Variance float64
+	Count    int64
+	Type     string
+	Group    string
+}
+
+// Curve is a correlation curve of a type.
+type Curve struct {
+	Lags      []int
+	Values    []float64
+	Variances []float64
+	Counts    []int64
+}
+
+// Param is a row of the tidy parameter table.
+type Param struct {
+	Sample    string
+	Group     string
+	Model     string
+	Name      string
+	Estimate  float64
+	SE        float64
+	N         int // number of fitted data points.
+	Converged bool
+}
+
+func main() {
+	app := kingpin.New("fit_p2", "Fit correlation functions from meta_p2 or collect_genes results")
+	app.Version("v0.1")
+	outFileArg := app.Arg("out-file", "output file").Required().String()
+	corrFilesArg := app.Arg("corr-files", "meta_p2 or collect_genes csv files, one per sample").Required().Strings()
+	modelsFlag := app.Flag("model", "fitting models: recomb, or registered curve models of P2").Default("recomb").Strings()
+	fitStartFlag := app.Flag("fit-start", "start lag of fitting (bp)").Default("1").Int()
+	fitEndFlag := app.Flag("fit-end", "end lag of fitting (bp, exclusive)").Default("100").Int()
+	byGroupFlag := app.Flag("by-group", "fit each gene group, instead of pooling groups").Default("false").Bool()
+	weightedFlag := app.Flag("weighted", "weight data points by their standard errors").Default("true").Bool()
+	correctedFlag := app.Flag("corrected", "fit sequencing-error corrected results").Default("false").Bool()
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	w, err := os.Create(*outFileArg)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer w.Close()
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+	cw.Write([]string{"sample", "group", "model", "parameter", "estimate", "se", "n", "converged"})
+
+	suffix := ""
+	if *correctedFlag {
+		suffix = "_corrected"
+	}
+
+	for _, corrFile := range *corrFilesArg {
+		sample := strings.TrimSuffix(filepath.Base(corrFile), filepath.Ext(corrFile))
+		results := readCorrResults(corrFile)
+		groups := groupResults(results, *byGroupFlag)
+
+		var groupNames []string
+		for name := range groups {
+			groupNames = append(groupNames, name)
+		}
+		sort.Strings(groupNames)
+
+		for _, group := range groupNames {
+			curves := groups[group]
+			ks := curves["Ks"+suffix]
+			p2 := curves["P2"+suffix]
+			p4 := curves["P4"+suffix]
+			if ks == nil || p2 == nil {
+				log.Printf("%s, %s: no Ks or P2 results\n", sample, group)
+				continue
+			}
+
+			for _, model := range *modelsFlag {
+				var params []Param
+				if model == "recomb" {
+					if p4 == nil {
+						log.Printf("%s, %s: no P4 results\n", sample, group)
+						continue
+					}
+					params = fitRecomb(ks, p2, p4, *fitStartFlag, *fitEndFlag, *weightedFlag)
+				} else if m, found := fit.Lookup(model); found {
+					params = fitModel(m, p2, *fitStartFlag, *fitEndFlag, *weightedFlag)
+				} else {
+					log.Fatalf("Unknown model: %s\n", model)
+				}
+
+				for _, p := range params {
+					p.Sample = sample
+					p.Group = group
+					p.Model = model
+					cw.Write([]string{p.Sample, p.Group, p.Model, p.Name,
+						formatFloat(p.Estimate), formatFloat(p.SE),
+						strconv.Itoa(p.N), strconv.FormatBool(p.Converged)})
+				}
+			}
+		}
+	}
+}
+
+// fitRecomb fits mutation and recombination parameters from Ks, P2 and P4,
+// which are normalized by Ks and scaled back before fitting.
+func fitRecomb(ks, p2, p4 *Curve, fitStart, fitEnd int, weighted bool) (params []Param) {
+	p4Map := make(map[int]float64)
+	for i, l := range p4.Lags {
+		p4Map[l] = p4.Values[i]
+	}
+
+	d := ks.Values[0]
+	l, p2s, p4s, ses := []float64{0}, []float64{d}, []float64{math.NaN()}, []float64{math.NaN()}
+	se := stdErrs(p2, weighted)
+	for i, lag := range p2.Lags {
+		v, found := p4Map[lag]
+		if lag >= fitStart && lag < fitEnd && found {
+			l = append(l, float64(lag))
+			p2s = append(p2s, p2.Values[i]*d)
+			p4s = append(p4s, v*d)
+			if se != nil {
+				ses = append(ses, se[i]*d)
+			}
+		}
+	}
+	if se == nil {
+		ses = nil
+	}
+
+	r := fit.FitRecombP2(l, p2s, p4s, ses)
+	names := []string{"ks", "theta", "phi", "rho", "ratio", "fragment", "amplitude", "background", "chi2"}
+	estimates := []float64{r.Ks, r.Theta, r.Phi, r.Rho, r.Ratio, r.Fragment, r.Amplitude, r.Background, r.Chi2}
+	ses = []float64{math.NaN(), r.ThetaSE, r.PhiSE, r.RhoSE, r.RatioSE, r.FragmentSE, r.AmplitudeSE, math.NaN(), math.NaN()}
+	for i, name := range names {
+		params = append(params, Param{Name: name, Estimate: estimates[i], SE: ses[i], N: r.N, Converged: r.Converged})
+	}
+	return
+}
+
+// fitModel fits a curve model to P2.
+func fitModel(m *fit.Model, p2 *Curve, fitStart, fitEnd int, weighted bool) (params []Param) {
+	se := stdErrs(p2, weighted)
+	x, y, ses := []float64{}, []float64{}, []float64{}
+	for i, lag := range p2.Lags {
+		if lag >= fitStart && lag < fitEnd {
+			x = append(x, float64(lag))
+			y = append(y, p2.Values[i])
+			if se != nil {
+				ses = append(ses, se[i])
+			}
+		}
+	}
+	if se == nil {
+		ses = nil
+	}
+	if len(x) <= len(m.Params) {
+		return
+	}
+
+	r := m.Fit(x, y, ses)
+	for i, name := range m.Params {
+		params = append(params, Param{Name: name, Estimate: r.Params[i], SE: r.SE[i], N: len(x), Converged: r.Converged})
+	}
+	for _, p := range []Param{{Name: "chi2", Estimate: r.Chi2}, {Name: "aic", Estimate: r.AIC}, {Name: "bic", Estimate: r.BIC}} {
+		p.SE = math.NaN()
+		p.N = len(x)
+		p.Converged = r.Converged
+		params = append(params, p)
+	}
+	return
+}
+
+// stdErrs returns standard errors of a curve, or nil if not weighted.
+func stdErrs(c *Curve, weighted bool) []float64 {
+	if !weighted {
+		return nil
+	}
+	n := make([]int, len(c.Counts))
+	for i := range c.Counts {
+		n[i] = int(c.Counts[i])
+	}
+	return fit.StdErrFromVar(c.Variances, n)
+}
+
+// groupResults collects curves by group and type.
+// If not byGroup, the "all" group is used if present
+// (collect_genes writes it along with gene groups),
+// otherwise all groups are pooled into one named "all".
+func groupResults(results []CorrResult, byGroup bool) map[string]map[string]*Curve {
+	if !byGroup {
+		var all []CorrResult
+		for _, res := range results {
+			if res.Group == "all" {
+				all = append(all, res)
+			}
+		}
+		if len(all) > 0 {
+			results = all
+		} else {
+			results = poolResults(results)
+		}
+	}
+
+	groups := make(map[string]map[string]*Curve)
+	for _, res := range results {
+		if _, found := groups[res.Group]; !found {
+			groups[res.Group] = make(map[string]*Curve)
+		}
+		c, found := groups[res.Group][res.Type]
+		if !found {
+			c = &Curve{}
+			groups[res.Group][res.Type] = c
+		}
+		c.Lags = append(c.Lags, res.Lag)
+		c.Values = append(c.Values, res.Value)
+		c.Variances = append(c.Variances, res.Variance)
+		c.Counts = append(c.Counts, res.Count)
+	}
+
+	for _, curves := range groups {
+		for _, c := range curves {
+			sort.Sort(byLag{c})
+		}
+	}
+	return groups
+}
+
+// poolResults pools results of all groups at each type and lag,
+// combining their means and variances weighted by counts.
+func poolResults(results []CorrResult) (pooled []CorrResult) {
+	type key struct {
+		typ string
+		lag int
+	}
+	type moments struct {
+		n     int64
+		sum   float64 // sum of values.
+		sumSq float64 // sum of squared values.
+	}
+	m := make(map[key]*moments)
+	var keys []key
+	for _, res := range results {
+		k := key{res.Type, res.Lag}
+		if _, found := m[k]; !found {
+			m[k] = &moments{}
+			keys = append(keys, k)
+		}
+		n := float64(res.Count)
+		m[k].n += res.Count
+		m[k].sum += n * res.Value
+		m[k].sumSq += n * (res.Variance + res.Value*res.Value)
+	}
+
+	for _, k := range keys {
+		mo := m[k]
+		res := CorrResult{Lag: k.lag, Type: k.typ, Group: "all", Count: mo.n}
+		if mo.n > 0 {
+			n := float64(mo.n)
+			res.Value = mo.sum / n
+			res.Variance = mo.sumSq/n - res.Value*res.Value
+		} else {
+			res.Value = math.NaN()
+			res.Variance = math.NaN()
+		}
+		pooled = append(pooled, res)
+	}
+	return
+}
+
+// byLag sorts a curve by lags.
+type byLag struct{ *Curve }
+
+func (c byLag) Len() int           { return len(c.Lags) }
+func (c byLag) Less(i, j int) bool { return c.Lags[i] < c.Lags[j] }
+func (c byLag) Swap(i, j int) {
+	c.Lags[i], c.Lags[j] = c.Lags[j], c.Lags[i]
+	c.Values[i], c.Values[j] = c.Values[j], c.Values[i]
+	c.Variances[i], c.Variances[j] = c.Variances[j], c.Variances[i]
+	c.Counts[i], c.Counts[j] = c.Counts[j], c.Counts[i]
+}
+
+// readCorrResults reads a l,m,v,n,t,b (or g) csv file,
+// skipping comment lines.
+func readCorrResults(filename string) (results []CorrResult) {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#' // such as the random seed of earlier meta_p2 outputs.
+	header, err := r.Read()
+	if err != nil {
+		log.Panicf("%s: %v\n", filename, err)
+	}
+	if len(header) < 6 || strings.Join(header[:5], ",") != "l,m,v,n,t" {
+		log.Panicf("%s: unexpected header %s\n", filename, strings.Join(header, ","))
+	}
+
+	for {
+		record, err := r.Read()
+		if err != nil {
+			if err != io.EOF {
+				log.Panicf("%s: %v\n", filename, err)
+			}
+			break
+		}
+
+		res := CorrResult{Type: record[4], Group: record[5]}
+		res.Lag, err = strconv.Atoi(record[0])
+		if err == nil {
+			res.Value, err = strconv.ParseFloat(record[1], 64)
+		}
+		if err == nil {
+			res.Variance, err = strconv.ParseFloat(record[2], 64)
+		}
+		if err == nil {
+			res.Count, err = strconv.ParseInt(record[3], 10, 64)
+		}
+		if err != nil {
+			log.Panicf("%s: %v\n", filename, err)
+		}
+		results = append(results, res)
+	}
+	return
+}
+
+// formatFloat formats a float, NA for NaN.
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return "NA"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
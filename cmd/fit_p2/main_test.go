@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Curves of Ks, and P2 and P4 normalized by Ks,
+// as written by meta_p2 and collect_genes.
+func recombCurves(ks, theta, phi, f, cr float64) (ksCurve, p2, p4 *Curve) {
+	a := ks * ks / (1 + 2*4.0/3.0*theta)
+	ksCurve = &Curve{Lags: []int{0}, Values: []float64{ks}, Variances: []float64{1e-6}, Counts: []int64{100}}
+	p2, p4 = &Curve{}, &Curve{}
+	for i := 1; i < 100; i++ {
+		l := 3 * i
+		cs := a / (1 + 2*phi*f*(1-math.Exp(-float64(l)/f)))
+		for _, c := range []*Curve{p2, p4} {
+			c.Lags = append(c.Lags, l)
+			c.Variances = append(c.Variances, 1e-6)
+			c.Counts = append(c.Counts, 100)
+		}
+		p4v := ks*ks + cr
+		p2.Values = append(p2.Values, (cs+p4v)/ks)
+		p4.Values = append(p4.Values, p4v/ks)
+	}
+	return
+}
+
+func TestFitRecombNormalized(t *testing.T) {
+	ks, theta, phi, f, cr := 0.05, 0.06, 0.002, 300.0, 1e-5
+	ksCurve, p2, p4 := recombCurves(ks, theta, phi, f, cr)
+	for _, weighted := range []bool{false, true} {
+		params := fitRecomb(ksCurve, p2, p4, 1, 300, weighted)
+		expected := map[string]float64{"ks": ks, "theta": theta, "phi": phi, "fragment": f, "background": cr}
+		for _, p := range params {
+			v, found := expected[p.Name]
+			if !found {
+				continue
+			}
+			if math.Abs(p.Estimate-v) > 1e-3*v {
+				t.Errorf("%s, Expect %f, got %f\n", p.Name, v, p.Estimate)
+			}
+			if p.N != 99 {
+				t.Errorf("%s, Expect %d points, got %d\n", p.Name, 99, p.N)
+			}
+		}
+	}
+}
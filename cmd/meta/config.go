@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"flag"
 	"github.com/jacobstr/confer"
+	"github.com/mingzhi/meta/cov"
+	"github.com/mingzhi/meta/fit"
+	"github.com/mingzhi/meta/ortho"
 	"github.com/mingzhi/meta/strain"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
@@ -25,6 +28,11 @@ type cmdConfig struct {
 	workspace *string // workspace.
 	config    *string // configure file name.
 	ncpu      *int    // number of CPUs for using.
+	seedFlag  *int64  // random seed, overriding the config.
+
+	// Random seed of all stochastic steps,
+	// from which each job derives its own generator.
+	seed int64
 
 	// Data diretory and path.
 	refBase string // reference genome folder.
@@ -46,20 +54,59 @@ type cmdConfig struct {
 	// Bowtie2 options.
 	bowtieOptions []string // bowtie2 options.
 
+	// Multiple aligner for ortho_aln.
+	alignProgram string   // muscle, muscle5, mafft, clustalo, prank or builtin.
+	alignOptions []string // options passed to the aligner.
+
+	// Homology search for ortho_mcl.
+	orthoSearch         string                // search backend: usearch, blastp, diamond or mmseqs.
+	orthoSearchOptions  ortho.SearchOptions   // search thresholds.
+	orthoParalogTargets int                   // max number of hits of searches for in-paralogs.
+	orthoCache          string                // folder caching search hits, in the ortho output folder.
+	orthoPermutations   int                   // number of strain orders of pangenome accumulation curves.
+	orthoMCLParams      ortho.MCLParams       // Markov clustering parameters.
+	orthoEdgeParams     ortho.EdgeParams      // edge weighting of MCL.
+	orthoPangenome      ortho.PangenomeParams // pangenome classification of clusters.
+
+	// Core alignment policy of cov_genomes, fit_genomes and fit_joint.
+	corePolicy ortho.CorePolicy
+
 	// For cov calculations.
 	positions     []int    // positions in genomic profile to be calculated.
 	maxl          int      // max length of correlations.
 	covReadsFuncs []string // cov calculation function name.
+	crossSamBase  string   // sam output folder of a second sample.
+	errorCorrect  bool     // whether to correct for sequencing errors.
+	subPrior      float64  // prior probability of a true difference of *_Qual functions.
 
 	// Species strain information.
 	speciesFile string                     // species YAML file.
 	speciesMap  map[string][]strain.Strain // species: []strain map.
 
 	// Fit parameters.
-	fitControls []fitControl
+	fitControls  []fitControl
+	fitProfileCI bool // calculate profile-likelihood confidence intervals.
+	fitResiduals bool // write residuals of bootstrap fits.
+
+	// Joint fit across strains of a species.
+	fitJointModels []string // models to fit jointly.
+	fitJointShared []string // names of parameters shared by strains.
 
 	// bootstrapping parameters.
 	numBoot int // number of bootstrapping
+
+	// Sliding window parameters.
+	scanWindowSize int // window size.
+	scanStepSize   int // step size between windows.
+
+	// Read simulation parameters.
+	simAbundanceFile string  // YAML file of relative abundances of strains.
+	simNumPairs      int     // total number of read pairs.
+	simReadLength    int     // read length.
+	simInsertSize    int     // mean insert size.
+	simInsertSD      float64 // standard deviation of insert sizes.
+	simErrorFirst    float64 // error rate at the first position of reads.
+	simErrorLast     float64 // error rate at the last position of reads.
 }
 
 // Implement command package interface.
@@ -68,6 +115,7 @@ func (cmd *cmdConfig) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.workspace = fs.String("w", "", "workspace.")
 	cmd.config = fs.String("c", "config.yaml", "configure files in YAML format, which are separeted by comma.")
 	cmd.ncpu = fs.Int("ncpu", runtime.NumCPU(), "number of CPUs for using.")
+	cmd.seedFlag = fs.Int64("seed", 0, "random seed, overriding the seed in configure files.")
 	return fs
 }
 
@@ -100,6 +148,12 @@ func (cmd *cmdConfig) ParseConfig() {
 	// Parse options for covariance calculation.
 	cmd.maxl = config.GetInt("cov.maxl")
 	cmd.covReadsFuncs = config.GetStringSlice("cov.functions")
+	cmd.crossSamBase = config.GetString("cov.cross_sam")
+	cmd.errorCorrect = config.GetBool("cov.error_correction")
+	cmd.subPrior = cov.DefaultSubPrior
+	if config.IsSet("cov.sub_prior") {
+		cmd.subPrior = config.GetFloat64("cov.sub_prior")
+	}
 	// Parse positions to be calculated.
 	positions := config.GetStringSlice("cov.positions")
 	for _, p := range positions {
@@ -111,24 +165,133 @@ func (cmd *cmdConfig) ParseConfig() {
 	}
 	// Parse bowtie2 options.
 	cmd.bowtieOptions = config.GetStringSlice("bowtie2.options")
+	// Parse multiple aligner.
+	cmd.alignProgram = config.GetString("align.program")
+	cmd.alignOptions = config.GetStringSlice("align.options")
+	// Parse homology search options.
+	cmd.orthoSearch = config.GetString("ortho.search")
+	cmd.orthoSearchOptions = ortho.DefaultSearchOptions
+	if config.IsSet("ortho.identity") {
+		cmd.orthoSearchOptions.Identity = config.GetFloat64("ortho.identity")
+	}
+	if config.IsSet("ortho.coverage") {
+		cmd.orthoSearchOptions.Coverage = config.GetFloat64("ortho.coverage")
+	}
+	if config.IsSet("ortho.subject_coverage") {
+		cmd.orthoSearchOptions.SCoverage = config.GetFloat64("ortho.subject_coverage")
+	}
+	if config.IsSet("ortho.evalue") {
+		cmd.orthoSearchOptions.EValue = config.GetFloat64("ortho.evalue")
+	}
+	if config.IsSet("ortho.max_targets") {
+		cmd.orthoSearchOptions.MaxTargets = config.GetInt("ortho.max_targets")
+	}
+	if config.IsSet("ortho.threads") {
+		cmd.orthoSearchOptions.Threads = config.GetInt("ortho.threads")
+	}
+	// Parse MCL parameters.
+	cmd.orthoMCLParams = ortho.DefaultMCLParams
+	if config.IsSet("ortho.mcl.inflation") {
+		cmd.orthoMCLParams.Inflation = config.GetFloat64("ortho.mcl.inflation")
+	}
+	if config.IsSet("ortho.mcl.prune") {
+		cmd.orthoMCLParams.PruneThreshold = config.GetFloat64("ortho.mcl.prune")
+	}
+	if config.IsSet("ortho.mcl.max_entries") {
+		cmd.orthoMCLParams.MaxEntries = config.GetInt("ortho.mcl.max_entries")
+	}
+	if config.IsSet("ortho.mcl.max_iter") {
+		cmd.orthoMCLParams.MaxIter = config.GetInt("ortho.mcl.max_iter")
+	}
+	// Parse edge weighting of MCL.
+	cmd.orthoEdgeParams = ortho.DefaultEdgeParams
+	if config.IsSet("ortho.edges.weight") {
+		cmd.orthoEdgeParams.Weight = config.GetString("ortho.edges.weight")
+	}
+	if config.IsSet("ortho.edges.normalize") {
+		cmd.orthoEdgeParams.Normalize = config.GetBool("ortho.edges.normalize")
+	}
+	if config.IsSet("ortho.edges.in_paralogs") {
+		cmd.orthoEdgeParams.InParalogs = config.GetBool("ortho.edges.in_paralogs")
+	}
+	if err := cmd.orthoEdgeParams.Validate(); err != nil {
+		ERROR.Fatalf("ortho.edges.weight: %v\n", err)
+	}
+	cmd.orthoCache = "search_cache"
+	if config.IsSet("ortho.cache") {
+		cmd.orthoCache = config.GetString("ortho.cache")
+	}
+	// Parse pangenome classification.
+	cmd.orthoPangenome = ortho.DefaultPangenomeParams
+	if config.IsSet("ortho.pangenome.core") {
+		cmd.orthoPangenome.Core = config.GetFloat64("ortho.pangenome.core")
+	}
+	if config.IsSet("ortho.pangenome.soft_core") {
+		cmd.orthoPangenome.SoftCore = config.GetFloat64("ortho.pangenome.soft_core")
+	}
+	if config.IsSet("ortho.pangenome.shell") {
+		cmd.orthoPangenome.Shell = config.GetFloat64("ortho.pangenome.shell")
+	}
+	cmd.orthoPermutations = 100
+	if config.IsSet("ortho.pangenome.permutations") {
+		cmd.orthoPermutations = config.GetInt("ortho.pangenome.permutations")
+	}
+	cmd.orthoParalogTargets = 10
+	if config.IsSet("ortho.edges.paralog_targets") {
+		cmd.orthoParalogTargets = config.GetInt("ortho.edges.paralog_targets")
+	}
+	// Parse core alignment policy of cov_genomes, fit_genomes and fit_joint.
+	cmd.corePolicy = ortho.DefaultCorePolicy
+	if config.IsSet("core.presence") {
+		cmd.corePolicy.Presence = config.GetFloat64("core.presence")
+	}
+	if config.IsSet("core.single_copy") {
+		cmd.corePolicy.SingleCopy = config.GetBool("core.single_copy")
+	}
+	if config.IsSet("core.paralogs") {
+		cmd.corePolicy.Paralogs = config.GetBool("core.paralogs")
+	}
 	// Parse the name of file storing bacterial strain information.
 	cmd.speciesFile = config.GetString("species.file")
 
-	// Fit range.
-	expFitControl := fitControl{}
-	expFitControl.start = config.GetInt("fit.exp.start")
-	expFitControl.end = config.GetInt("fit.exp.end")
-	expFitControl.name = "exp"
-	cmd.fitControls = append(cmd.fitControls, expFitControl)
-	hyperFitControl := fitControl{}
-	hyperFitControl.start = config.GetInt("fit.hyper.start")
-	hyperFitControl.end = config.GetInt("fit.hyper.end")
-	hyperFitControl.name = "hyper"
-	cmd.fitControls = append(cmd.fitControls, hyperFitControl)
+	// Fit ranges of registered models and recombination inference.
+	for _, name := range append(fit.Models(), "recomb") {
+		fitCon := fitControl{}
+		fitCon.start = config.GetInt("fit." + name + ".start")
+		fitCon.end = config.GetInt("fit." + name + ".end")
+		fitCon.name = name
+		cmd.fitControls = append(cmd.fitControls, fitCon)
+	}
+	cmd.fitProfileCI = config.GetBool("fit.profile_ci")
+	cmd.fitResiduals = config.GetBool("fit.residuals")
+	cmd.fitJointModels = config.GetStringSlice("fit.joint.models")
+	cmd.fitJointShared = config.GetStringSlice("fit.joint.shared")
 
 	// Bootstrapping
 	cmd.numBoot = config.GetInt("bootstrapping.number")
 
+	// Random seed, from the flag, configure files, or default to 1.
+	cmd.seed = config.GetInt64("seed")
+	if *cmd.seedFlag != 0 {
+		cmd.seed = *cmd.seedFlag
+	}
+	if cmd.seed == 0 {
+		cmd.seed = 1
+	}
+
+	// Sliding window.
+	cmd.scanWindowSize = config.GetInt("scan.window")
+	cmd.scanStepSize = config.GetInt("scan.step")
+
+	// Read simulation.
+	cmd.simAbundanceFile = config.GetString("simulate.abundance_file")
+	cmd.simNumPairs = config.GetInt("simulate.pairs")
+	cmd.simReadLength = config.GetInt("simulate.read_length")
+	cmd.simInsertSize = config.GetInt("simulate.insert_size")
+	cmd.simInsertSD = config.GetFloat64("simulate.insert_sd")
+	cmd.simErrorFirst = config.GetFloat64("simulate.error_first")
+	cmd.simErrorLast = config.GetFloat64("simulate.error_last")
+
 	runtime.GOMAXPROCS(*cmd.ncpu)
 }
 
@@ -7,11 +7,11 @@ import (
 	"fmt"
 	"github.com/mingzhi/meta/cov"
 	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/sim"
 	"github.com/mingzhi/meta/strain"
 	"github.com/mingzhi/ncbiftp/seqrecord"
 	"log"
 	"math"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -67,12 +67,10 @@ func (cmd *cmdCovGenomes) Run(args []string) {
 					p = strings.Join([]string{prefix, appendix}, "_")
 				}
 				alignments := cmd.ReadAlignments(p)
-				cores, disps := separeteAlignments(alignments, strains)
-				alignmentTypes := []string{"core", "disp", "pan"}
-				alignmentArray := [][]seqrecord.SeqRecords{cores, disps, alignments}
-				for i := 0; i < len(alignmentTypes); i++ {
-					name := alignmentTypes[i]
-					alns := alignmentArray[i]
+				policy := cmd.corePolicy
+				groups := policy.Separate(alignments, strains)
+				for _, name := range policy.Types() {
+					alns := groups[name]
 					if len(alns) == 0 {
 						WARN.Printf("%s, %s alignments has zero record.\n", prefix, name)
 					} else {
@@ -93,23 +91,6 @@ func (cmd *cmdCovGenomes) Run(args []string) {
 	}
 }
 
-func separeteAlignments(alignments []seqrecord.SeqRecords, strains []strain.Strain) (cores, disps []seqrecord.SeqRecords) {
-	for _, aln := range alignments {
-		genomeSet := make(map[string]bool)
-		for _, rec := range aln {
-			genomeSet[rec.Genome] = true
-		}
-
-		if len(genomeSet) == len(strains) {
-			cores = append(cores, aln)
-		} else if len(genomeSet) < len(strains) {
-			disps = append(disps, aln)
-		}
-	}
-
-	return
-}
-
 func (cmd *cmdCovGenomes) RunOne(strains []strain.Strain, alignments []seqrecord.SeqRecords, pos int, name string) {
 	// For each strain (genome), create a job.
 	type job struct {
@@ -156,6 +137,7 @@ func (cmd *cmdCovGenomes) RunOne(strains []strain.Strain, alignments []seqrecord
 					funcType := covGenomesFuncNames[j]
 					cc := cov.GenomesCalc(alignments, g, cmd.maxl, pos, covGenomesFunc)
 					res := createCovResult(cc, cmd.maxl, pos)
+					res.Seed = cmd.seed
 					// Write result to files.
 					filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", g.RefAcc(),
 						funcType, name, pos)
@@ -168,7 +150,7 @@ func (cmd *cmdCovGenomes) RunOne(strains []strain.Strain, alignments []seqrecord
 					}
 
 					if cmd.numBoot > 0 {
-						ccChan := cmd.boot(cc, cmd.numBoot)
+						ccChan := cmd.boot(cc, cmd.numBoot, filePrefix)
 						resChan := cmd.collectBoot(ccChan, pos, cmd.maxl)
 						// Write result to files.
 						filePath := filepath.Join(*cmd.workspace, cmd.covOutBase, s.Path,
@@ -226,33 +208,38 @@ func (cmd *cmdCovGenomes) ReadAlignments(prefix string) (alns []seqrecord.SeqRec
 	return
 }
 
-func (cmd *cmdCovGenomes) boot(cc []*cov.Calculators, numBoot int) (ccChan chan []*cov.Calculators) {
+// A bootstrap replicate.
+type bootSample struct {
+	index int // 1-based index of the replicate.
+	cc    []*cov.Calculators
+}
+
+// Bootstrap calculators of a job, named by its output file prefix.
+// Each replicate is resampled by its own random generator,
+// derived from the seed, the job name and its index.
+func (cmd *cmdCovGenomes) boot(cc []*cov.Calculators, numBoot int, name string) (ccChan chan bootSample) {
 	// bootstrapping
-	bootJobs := make(chan []int)
+	bootJobs := make(chan int)
 	go func() {
 		defer close(bootJobs)
-		for i := 0; i < numBoot; i++ {
-			sample := make([]int, len(cc))
-			for j := 0; j < len(sample); j++ {
-				sample[j] = rand.Intn(len(cc))
-			}
-			bootJobs <- sample
+		for i := 1; i <= numBoot; i++ {
+			bootJobs <- i
 		}
 	}()
 
-	ccChan = make(chan []*cov.Calculators)
+	ccChan = make(chan bootSample)
 	ncpu := *cmd.ncpu
 	done := make(chan bool)
 	for i := 0; i < ncpu; i++ {
 		go func() {
-			for sample := range bootJobs {
+			for index := range bootJobs {
+				rng := sim.NewJobRand(cmd.seed, name, index)
 				randomCC := []*cov.Calculators{}
-				for j := 0; j < len(sample); j++ {
-					index := sample[j]
-					c := cc[index]
+				for j := 0; j < len(cc); j++ {
+					c := cc[rng.Intn(len(cc))]
 					randomCC = append(randomCC, c)
 				}
-				ccChan <- randomCC
+				ccChan <- bootSample{index, randomCC}
 			}
 			done <- true
 		}()
@@ -268,27 +255,45 @@ func (cmd *cmdCovGenomes) boot(cc []*cov.Calculators, numBoot int) (ccChan chan
 	return
 }
 
-func (cmd *cmdCovGenomes) collectBoot(ccChan chan []*cov.Calculators, pos, maxl int) (resChan chan CovResult) {
-	resChan = make(chan CovResult)
+// Collect results of bootstrap replicates, in the order of their indices.
+func (cmd *cmdCovGenomes) collectBoot(ccChan chan bootSample, pos, maxl int) (resChan chan CovResult) {
+	unordered := make(chan CovResult)
 	numWorker := *cmd.ncpu
 	done := make(chan bool)
 	for i := 0; i < numWorker; i++ {
 		go func() {
-			for cc := range ccChan {
-				res := createCovResult(cc, maxl, pos)
-				resChan <- res
+			for sample := range ccChan {
+				res := createCovResult(sample.cc, maxl, pos)
+				res.Seed = cmd.seed
+				res.Boot = sample.index
+				unordered <- res
 			}
 			done <- true
 		}()
 	}
 
 	go func() {
-		defer close(resChan)
+		defer close(unordered)
 		for i := 0; i < numWorker; i++ {
 			<-done
 		}
 	}()
 
+	resChan = make(chan CovResult)
+	go func() {
+		defer close(resChan)
+		pending := make(map[int]CovResult)
+		next := 1
+		for res := range unordered {
+			pending[res.Boot] = res
+			for r, found := pending[next]; found; r, found = pending[next] {
+				resChan <- r
+				delete(pending, next)
+				next++
+			}
+		}
+	}()
+
 	return
 }
 
@@ -68,7 +68,16 @@ func (cmd *cmdCovReads) Run(args []string) {
 
 							// paired end reads and sorted.
 							matedReads := reads.GetPairedEndReads(records)
+
+							// estimate sequencing error rate from overlapping mates.
+							errRate := math.NaN()
+							if cmd.errorCorrect {
+								errRate = cov.EstimateMateError(matedReads).Rate()
+								INFO.Printf("%s,%s error rate: %g\n", s.Path, g.RefAcc(), errRate)
+							}
 							for _, funcName := range cmd.covReadsFuncs {
+								// error rate of reads compared to records.
+								crossErrRate := errRate
 								// Assign cov read function.
 								switch funcName {
 								case "Cov_Reads_vs_Reads":
@@ -76,6 +85,24 @@ func (cmd *cmdCovReads) Run(args []string) {
 									sort.Sort(reads.ByRightCoordinatePairedEndReads{matedReads})
 								case "Cov_Reads_vs_Genome":
 									cmd.covFunc = cov.ReadsVsGenome
+								case "Cov_Reads_vs_Reads_Qual":
+									cmd.covFunc = qualCovFunc(cov.ReadsVsReadsQual, cmd.subPrior)
+									sort.Sort(reads.ByRightCoordinatePairedEndReads{matedReads})
+								case "Cov_Reads_vs_Genome_Qual":
+									cmd.covFunc = qualCovFunc(cov.ReadsVsGenomeQual, cmd.subPrior)
+								case "Cov_Reads_vs_Genome_Dist", "Cov_Reads_vs_Reads_Dist":
+									cmd.CovDist(funcName, matedReads, g, base, s.Path)
+									continue
+								case "Cov_Reads_vs_Reads_Cross", "Cov_Reads_vs_Reads_Cross_Qual", "Cov_Allele_Freqs_Cross":
+									crossReads, found := cmd.readCrossReads(s.Path, g.RefAcc())
+									if !found {
+										continue
+									}
+									if cmd.errorCorrect {
+										crossErrRate = cov.EstimateMateError(crossReads).Rate()
+										INFO.Printf("%s,%s cross error rate: %g\n", s.Path, g.RefAcc(), crossErrRate)
+									}
+									cmd.covFunc = crossCovFunc(funcName, crossReads, cmd.subPrior)
 								default:
 									continue
 								}
@@ -83,6 +110,12 @@ func (cmd *cmdCovReads) Run(args []string) {
 								// Calculate correlations at each position.
 								for _, pos := range cmd.positions {
 									res := cmd.Cov(matedReads, g, pos)
+									// *_Qual functions down-weight errors by base qualities,
+									// and are not corrected again.
+									if !math.IsNaN(errRate) && !strings.HasSuffix(funcName, "_Qual") {
+										res.ErrRate = errRate
+										correctCovResult(&res, errModel(funcName, errRate, crossErrRate))
+									}
 									// Write result to files.
 									filePrefix := fmt.Sprintf("%s_%s_pos%d", g.RefAcc(),
 										funcName, pos)
@@ -109,17 +142,66 @@ func (cmd *cmdCovReads) Run(args []string) {
 
 }
 
+// Read paired-end reads of the second sample,
+// which are mapped to the same reference genome.
+func (cmd *cmdCovReads) readCrossReads(strainPath, refAcc string) (matedReads reads.PairedEndReads, found bool) {
+	if cmd.crossSamBase == "" {
+		WARN.Println("Cross-sample functions require cov.cross_sam!")
+		return
+	}
+
+	crossBase := cmd.crossSamBase
+	if !filepath.IsAbs(crossBase) {
+		crossBase = filepath.Join(*cmd.workspace, crossBase)
+	}
+	samFilePath := filepath.Join(crossBase, strainPath, refAcc+bowtiedSamAppendix)
+	if !isSamFileExist(samFilePath) {
+		return
+	}
+
+	_, records := reads.ReadSamFile(samFilePath)
+	if len(records) == 0 {
+		WARN.Printf("%s has zero records\n", samFilePath)
+		return
+	}
+
+	matedReads = reads.GetPairedEndReads(records)
+	found = true
+	return
+}
+
+// Return the quality-weighted cov function with the prior probability of a true difference.
+func qualCovFunc(f func(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int, prior float64) (*cov.KsCalculator, *cov.CovCalculator), prior float64) covReadsFunc {
+	return func(records reads.PairedEndReads, g genome.Genome, maxl, pos int) (*cov.KsCalculator, *cov.CovCalculator) {
+		return f(records, g, maxl, pos, prior)
+	}
+}
+
+// Return the cov function comparing records to reads of a second sample,
+// quality-weighted with the prior probability of a true difference for *_Qual.
+func crossCovFunc(funcName string, crossReads reads.PairedEndReads, prior float64) covReadsFunc {
+	var f func(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int) (*cov.KsCalculator, *cov.CovCalculator)
+	switch funcName {
+	case "Cov_Reads_vs_Reads_Cross_Qual":
+		f = func(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int) (*cov.KsCalculator, *cov.CovCalculator) {
+			return cov.ReadsVsReadsCrossQual(matedReads1, matedReads2, g, maxl, pos, prior)
+		}
+	case "Cov_Allele_Freqs_Cross":
+		f = cov.AlleleFreqsCross
+	default:
+		f = cov.ReadsVsReadsCross
+	}
+	return func(records reads.PairedEndReads, g genome.Genome, maxl, pos int) (*cov.KsCalculator, *cov.CovCalculator) {
+		return f(records, crossReads, g, maxl, pos)
+	}
+}
+
 // Calculate covariance for records.
 func (cmd *cmdCovReads) Cov(records reads.PairedEndReads,
 	g genome.Genome, pos int) (res CovResult) {
 
 	kc, cc := cmd.covFunc(records, g, cmd.maxl, pos)
 
-	// Process and return a cov result.
-	res.Ks = kc.Mean.GetResult()
-	res.VarKs = kc.Var.GetResult()
-	res.N = kc.Mean.GetN()
-
 	// To use base distiance (step = 1) or codon distance (step = 3)
 	var step, size int
 	if pos == 0 {
@@ -130,6 +212,53 @@ func (cmd *cmdCovReads) Cov(records reads.PairedEndReads,
 		size = cmd.maxl / 3
 	}
 
+	return createReadsCovResult(kc, cc, step, size)
+}
+
+// Calculate covariance by genome distance for records,
+// and write results of same-gene, different-gene, same-strand and opposite-strand site pairs.
+func (cmd *cmdCovReads) CovDist(funcName string, records reads.PairedEndReads,
+	g genome.Genome, base, strainPath string) {
+	genome.LoadGenes(&g, base)
+	if len(g.Genes) == 0 {
+		WARN.Printf("%s,%s has zero genes\n", strainPath, g.RefAcc())
+		return
+	}
+
+	for _, pos := range cmd.positions {
+		var c *cov.DistCalculators
+		if funcName == "Cov_Reads_vs_Reads_Dist" {
+			sort.Sort(reads.ByRightCoordinatePairedEndReads{records})
+			c = cov.ReadsVsReadsByDist(records, g, cmd.maxl, pos)
+		} else {
+			c = cov.ReadsVsGenomeByDist(records, g, cmd.maxl, pos)
+		}
+
+		pairTypes := []string{"same_gene", "different_gene", "same_strand", "opposite_strand"}
+		calculators := []*cov.CovCalculator{c.SameGene, c.DifferentGene, c.SameStrand, c.OppositeStrand}
+		for i, pairType := range pairTypes {
+			// Use base distance across gene boundaries.
+			res := createReadsCovResult(c.Ks, calculators[i], 1, cmd.maxl)
+			filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", g.RefAcc(),
+				funcName, pairType, pos)
+			filePath := filepath.Join(*cmd.workspace, cmd.covOutBase, strainPath,
+				filePrefix+".json")
+			if !math.IsNaN(res.VarKs) {
+				res.NReads = len(records)
+				save2Json(res, filePath)
+			} else {
+				WARN.Printf("%s: VarKs: NaN\n", filePath)
+			}
+		}
+	}
+}
+
+// Process and return a cov result.
+func createReadsCovResult(kc *cov.KsCalculator, cc *cov.CovCalculator, step, size int) (res CovResult) {
+	res.Ks = kc.Mean.GetResult()
+	res.VarKs = kc.Var.GetResult()
+	res.N = kc.Mean.GetN()
+
 	for i := 0; i < size; i++ {
 		index := step * i
 		v := cc.GetResult(index)
@@ -144,6 +273,32 @@ func (cmd *cmdCovReads) Cov(records reads.PairedEndReads,
 	return
 }
 
+// Return the error model of a cov read function,
+// which compares reads of error rate e1 either to the reference genome,
+// or to reads of error rate e2, of the same or a second sample.
+func errModel(funcName string, e1, e2 float64) *cov.ErrorModel {
+	if strings.Contains(funcName, "Genome") {
+		return cov.NewErrorModel(e1, 0)
+	}
+	return cov.NewErrorModel(e1, e2)
+}
+
+// Add error-corrected Ks and Ct to a cov result.
+func correctCovResult(res *CovResult, m *cov.ErrorModel) {
+	ks := m.CorrectKs(res.Ks)
+	if math.IsNaN(ks) || math.IsInf(ks, 0) {
+		return
+	}
+	res.KsCorrected = ks
+	for i, v := range res.Ct {
+		if res.CtIndices[i] == 0 {
+			res.CtCorrected = append(res.CtCorrected, ks*(1-ks))
+		} else {
+			res.CtCorrected = append(res.CtCorrected, m.CorrectCov(v))
+		}
+	}
+}
+
 // Check if it is a chromosome,
 // by simply searching "chromosome" keyword.
 func isChromosome(replicon string) (is bool) {
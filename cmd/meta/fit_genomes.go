@@ -12,6 +12,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 type cmdFitGenomes struct {
@@ -36,6 +39,7 @@ func (cmd *cmdFitGenomes) Init() {
 func (cmd *cmdFitGenomes) Run(args []string) {
 	cmd.Init()
 	type job struct {
+		prefix  string
 		strains []strain.Strain
 		pos     int
 		typ     string
@@ -44,11 +48,12 @@ func (cmd *cmdFitGenomes) Run(args []string) {
 	jobs := make(chan job)
 	go func() {
 		defer close(jobs)
-		for _, strains := range cmd.speciesMap {
+		for prefix, strains := range cmd.speciesMap {
 			for _, pos := range cmd.positions {
-				for _, name := range []string{"core", "disp", "pan"} {
+				for _, name := range cmd.corePolicy.Types() {
 					for _, funcType := range []string{"Cov_Genomes_vs_Genome", "Cov_Genomes_vs_Genomes"} {
 						j := job{}
+						j.prefix = prefix
 						j.strains = strains
 						j.pos = pos
 						j.typ = name
@@ -69,7 +74,7 @@ func (cmd *cmdFitGenomes) Run(args []string) {
 				name := j.typ
 				funcType := j.funcT
 				strains := j.strains
-				cmd.RunOne(strains, pos, name, funcType)
+				cmd.RunOne(j.prefix, strains, pos, name, funcType)
 			}
 			done <- true
 		}()
@@ -80,7 +85,12 @@ func (cmd *cmdFitGenomes) Run(args []string) {
 	}
 }
 
-func (cmd *cmdFitGenomes) RunOne(strains []strain.Strain, pos int, name string, funcType string) {
+func (cmd *cmdFitGenomes) RunOne(prefix string, strains []strain.Strain, pos int, name string, funcType string) {
+	alnType := name
+	// fit results of each model of all strains, for species summaries.
+	var mutex sync.Mutex
+	speciesFits := make(map[string][][]FitResult)
+
 	jobs := make(chan strain.Strain)
 	go func() {
 		defer close(jobs)
@@ -98,27 +108,51 @@ func (cmd *cmdFitGenomes) RunOne(strains []strain.Strain, pos int, name string,
 				for _, g := range s.Genomes {
 					filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", g.RefAcc(), funcType, name, pos)
 					filePath := filepath.Join(*cmd.workspace, cmd.covOutBase, s.Path, filePrefix+"_boot.json.zip")
-					var f fitFunc
+					// cov_genomes skips types without alignments.
+					if _, err := os.Stat(filePath); err != nil {
+						WARN.Printf("%s: %v\n", filePrefix, err)
+						continue
+					}
+					selections := []ModelSelection{}
 					for _, fitCon := range cmd.fitControls {
 						if fitCon.end-fitCon.start > 0 {
 							name := fitCon.name
-							switch name {
-							case "exp":
-								f = fitExp
-							case "hyper":
-								f = fitHyper
-							default:
-								f = nil
-							}
-
-							if f != nil {
+							var fitResChan chan FitResult
+							if name == "recomb" {
 								resChan := fromJson(filePath)
-								fitResChan := doFit(f, resChan, fitCon.start, fitCon.end)
-								fitFileOutPath := filepath.Join(*cmd.workspace, cmd.fitOutBase, s.Path, filePrefix+"_"+name+"_boot.json")
-								toJson(fitFileOutPath, fitResChan)
+								fitResChan = doFitRecomb(resChan, fitCon.start, fitCon.end, pos)
+							} else if m, found := fit.Lookup(name); found {
+								resChan := fromJson(filePath)
+								fitResChan = doFit(modelFitFunc(m, cmd.fitProfileCI, cmd.fitResiduals), resChan, fitCon.start, fitCon.end)
+							} else {
+								WARN.Printf("Unknown fit model: %s\n", name)
+								continue
+							}
+							fitFileOutPath := filepath.Join(*cmd.workspace, cmd.fitOutBase, s.Path, filePrefix+"_"+name+"_boot.json")
+							// failed and unconverged fits are written but not summarized.
+							fitResults := validFits(toJson(fitFileOutPath, fitResChan))
+							// recomb fits the linkage covariance at positive distances,
+							// whose AIC and BIC are not comparable to those of curve models of Ct.
+							if name != "recomb" {
+								selections = append(selections, selectModel(name, fitResults))
 							}
+
+							summary := FitSummary{Genome: g.RefAcc(), AlnType: alnType, FuncType: funcType, Pos: pos, Model: name}
+							summary.NBoot = len(fitResults)
+							summary.Params = summarizeFits(fitResults)
+							summaryFilePath := filepath.Join(*cmd.workspace, cmd.fitOutBase, s.Path, filePrefix+"_"+name+"_summary.json")
+							saveSummary(summaryFilePath, summary)
+
+							mutex.Lock()
+							speciesFits[name] = append(speciesFits[name], fitResults)
+							mutex.Unlock()
 						}
 					}
+					if len(selections) > 0 {
+						markBestModels(selections)
+						selectionFilePath := filepath.Join(*cmd.workspace, cmd.fitOutBase, s.Path, filePrefix+"_model_selection.json")
+						saveModelSelections(selectionFilePath, selections)
+					}
 				}
 			}
 			done <- true
@@ -128,35 +162,195 @@ func (cmd *cmdFitGenomes) RunOne(strains []strain.Strain, pos int, name string,
 	for i := 0; i < ncpu; i++ {
 		<-done
 	}
+
+	for model, strainFits := range speciesFits {
+		summary := SpeciesFitSummary{Species: prefix, AlnType: alnType, FuncType: funcType, Pos: pos, Model: model}
+		summary.Pooled, summary.AcrossStrains, summary.NStrains = combineStrains(strainFits)
+		filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", prefix, funcType, alnType, pos)
+		summaryFilePath := filepath.Join(*cmd.workspace, cmd.fitOutBase, filePrefix+"_"+model+"_summary.json")
+		saveSummary(summaryFilePath, summary)
+	}
+
 }
 
+// FitResult is a fit of a bootstrap replicate,
+// in which NaN or infinite values are encoded as null.
 type FitResult struct {
-	Ks         float64
-	B0, B1, B2 float64
+	Ks         jsonFloat
+	B0, B1, B2 jsonFloat
+
+	// Model parameters.
+	Model  string
+	Params []jsonFloat
+
+	// Goodness of fit, weighted by standard errors of Ct,
+	// with residuals only if they are configured.
+	Chi2      jsonFloat
+	DoF       int
+	RedChi2   jsonFloat
+	Residuals []jsonFloat `json:",omitempty"`
+	AIC, BIC  jsonFloat
+
+	// Convergence status.
+	Converged    bool
+	Iterations   int
+	ResidualNorm jsonFloat
+	Status       string
+
+	// Standard errors and profile-likelihood 95% confidence intervals
+	// of parameters, null if they cannot be estimated.
+	SE      []jsonFloat `json:",omitempty"`
+	CILower []jsonFloat `json:",omitempty"`
+	CIUpper []jsonFloat `json:",omitempty"`
+
+	// Mutation and recombination parameters.
+	Recomb *RecombFit `json:",omitempty"`
+
+	// Random seed and index of the fitted bootstrap replicate.
+	Seed int64 `json:",omitempty"`
+	Boot int   `json:",omitempty"`
+}
+
+// RecombFit contains mutation and recombination parameters
+// and their standard errors (see fit.RecombResult),
+// of which derived ones may be null although the fit succeeded.
+type RecombFit struct {
+	Theta, Phi, Rho, Ratio, Fragment, Amplitude, Background jsonFloat
+
+	ThetaSE, PhiSE, RhoSE, RatioSE, FragmentSE, AmplitudeSE jsonFloat
+
+	N    int
+	Chi2 jsonFloat
+	DoF  int
 }
 
-type fitFunc func(xdata, ydata []float64) FitResult
+func newRecombFit(r fit.RecombResult) *RecombFit {
+	return &RecombFit{
+		Theta:       jsonFloat(r.Theta),
+		Phi:         jsonFloat(r.Phi),
+		Rho:         jsonFloat(r.Rho),
+		Ratio:       jsonFloat(r.Ratio),
+		Fragment:    jsonFloat(r.Fragment),
+		Amplitude:   jsonFloat(r.Amplitude),
+		Background:  jsonFloat(r.Background),
+		ThetaSE:     jsonFloat(r.ThetaSE),
+		PhiSE:       jsonFloat(r.PhiSE),
+		RhoSE:       jsonFloat(r.RhoSE),
+		RatioSE:     jsonFloat(r.RatioSE),
+		FragmentSE:  jsonFloat(r.FragmentSE),
+		AmplitudeSE: jsonFloat(r.AmplitudeSE),
+		N:           r.N,
+		Chi2:        jsonFloat(r.Chi2),
+		DoF:         r.DoF,
+	}
+}
+
+// Fit function, weighted by the standard errors sedata.
+type fitFunc func(xdata, ydata, sedata []float64) FitResult
 
 func doFit(f fitFunc, resChan chan CovResult, fitStart, fitEnd int) (fitResChan chan FitResult) {
 	ncpu := runtime.GOMAXPROCS(0)
 	done := make(chan bool)
 	fitResChan = make(chan FitResult)
+	var failed, fewPoints int64 // numbers of failed fits.
 	for i := 0; i < ncpu; i++ {
 		go func() {
 			for r := range resChan {
 				xdata := []float64{}
 				ydata := []float64{}
+				indices := []int{}
 				for i := 0; i < len(r.CtIndices) && r.CtIndices[i] < fitEnd; i++ {
 					if r.CtIndices[i] >= fitStart {
 						xdata = append(xdata, float64(r.CtIndices[i]))
 						ydata = append(ydata, r.Ct[i])
+						indices = append(indices, i)
+					}
+				}
+				res := f(xdata, ydata, ctStdErrs(r, indices))
+				res.Ks = jsonFloat(r.Ks)
+				res.Seed, res.Boot = r.Seed, r.Boot
+				if res.DoF <= 0 {
+					atomic.AddInt64(&fewPoints, 1)
+					failFit(&res, "too few points")
+				} else if isNaN(res) {
+					atomic.AddInt64(&failed, 1)
+					failFit(&res, "NaN estimates")
+				}
+				fitResChan <- res
+			}
+			done <- true
+		}()
+	}
+
+	go func() {
+		defer close(fitResChan)
+		for i := 0; i < ncpu; i++ {
+			<-done
+		}
+		warnFailed(failed, fewPoints)
+	}()
+
+	return
+}
+
+// Infer mutation and recombination parameters
+// from Ct and Cr in the fitting range.
+func doFitRecomb(resChan chan CovResult, fitStart, fitEnd, pos int) (fitResChan chan FitResult) {
+	// To use base distiance (step = 1) or codon distance (step = 3)
+	step := 3
+	if pos == 0 {
+		step = 1
+	}
+
+	ncpu := runtime.GOMAXPROCS(0)
+	done := make(chan bool)
+	fitResChan = make(chan FitResult)
+	var failed, fewPoints int64 // numbers of failed fits.
+	for i := 0; i < ncpu; i++ {
+		go func() {
+			for r := range resChan {
+				crMap := make(map[int]float64)
+				for i, l := range r.CrIndices {
+					crMap[l] = r.Cr[i]
+				}
+
+				ldata := []float64{}
+				ctdata := []float64{}
+				crdata := []float64{}
+				indices := []int{}
+				for i := 0; i < len(r.CtIndices) && r.CtIndices[i] < fitEnd; i++ {
+					cr, found := crMap[r.CtIndices[i]]
+					if r.CtIndices[i] >= fitStart && found {
+						ldata = append(ldata, float64(r.CtIndices[i]*step))
+						ctdata = append(ctdata, r.Ct[i])
+						crdata = append(crdata, cr)
+						indices = append(indices, i)
 					}
 				}
-				res := f(xdata, ydata)
-				res.Ks = r.Ks
-				if !isNaN(res) {
-					fitResChan <- res
+
+				recomb := fit.FitRecombCt(r.Ks, ldata, ctdata, crdata, ctStdErrs(r, indices))
+				res := FitResult{Ks: jsonFloat(r.Ks), Recomb: newRecombFit(recomb), Seed: r.Seed, Boot: r.Boot}
+				res.Converged = recomb.Converged
+				res.Iterations = recomb.Iterations
+				res.ResidualNorm = jsonFloat(recomb.ResidualNorm)
+				res.Status = recomb.Message
+				res.Model = "recomb"
+				res.Params = toJsonFloats([]float64{recomb.Amplitude, recomb.Phi, recomb.Fragment})
+				res.Chi2 = jsonFloat(recomb.Chi2)
+				res.DoF = recomb.DoF
+				res.RedChi2 = jsonFloat(recomb.Chi2 / float64(recomb.DoF))
+				aic, bic := fit.InfoCriteria(recomb.Chi2, recomb.DoF, len(res.Params))
+				res.AIC, res.BIC = jsonFloat(aic), jsonFloat(bic)
+				// derived parameters and standard errors may be NaN,
+				// such as theta of a too large amplitude.
+				if res.DoF <= 0 {
+					atomic.AddInt64(&fewPoints, 1)
+					failFit(&res, "too few points")
+				} else if anyNaN(res.Params...) {
+					atomic.AddInt64(&failed, 1)
+					failFit(&res, "NaN estimates")
 				}
+				fitResChan <- res
 			}
 			done <- true
 		}()
@@ -167,39 +361,225 @@ func doFit(f fitFunc, resChan chan CovResult, fitStart, fitEnd int) (fitResChan
 		for i := 0; i < ncpu; i++ {
 			<-done
 		}
+		warnFailed(failed, fewPoints)
 	}()
 
 	return
 }
 
-func fitHyper(xdata, ydata []float64) (res FitResult) {
-	par := fit.FitHyper(xdata, ydata)
-	res.B0 = par[0]
-	res.B1 = par[1]
+// Warn about failed fits, with NaN or infinite estimates,
+// or with no more data points than parameters.
+func warnFailed(failed, fewPoints int64) {
+	if failed > 0 {
+		WARN.Printf("%d fits failed with NaN or infinite estimates\n", failed)
+	}
+	if fewPoints > 0 {
+		WARN.Printf("%d fits failed with no more data points than parameters\n", fewPoints)
+	}
+}
+
+// Mark a fit as failed, with NaN parameters,
+// which is kept in outputs but skipped in summaries.
+func failFit(res *FitResult, status string) {
+	res.Converged = false
+	res.Status = "failed: " + status
+	nan := jsonFloat(math.NaN())
+	res.B0, res.B1, res.B2 = nan, nan, nan
+	for i := range res.Params {
+		res.Params[i] = nan
+	}
+}
+
+// Fits that converged with estimates.
+func validFits(fitResults []FitResult) (valid []FitResult) {
+	for _, res := range fitResults {
+		if res.Converged && !anyNaN(res.Params...) {
+			valid = append(valid, res)
+		}
+	}
+	return
+}
+
+// Fit function of a registered model,
+// with profile-likelihood confidence intervals if profileCI,
+// and residuals if residuals.
+func modelFitFunc(m *fit.Model, profileCI, residuals bool) fitFunc {
+	return func(xdata, ydata, sedata []float64) (res FitResult) {
+		mf := m.Fit(xdata, ydata, sedata)
+		res.Model = mf.Model
+		res.Params = toJsonFloats(mf.Params)
+		// B0, B1, B2 keep the first three parameters.
+		bs := []*jsonFloat{&res.B0, &res.B1, &res.B2}
+		for i := 0; i < len(bs) && i < len(mf.Params); i++ {
+			*bs[i] = jsonFloat(mf.Params[i])
+		}
+		res.Chi2 = jsonFloat(mf.Chi2)
+		res.DoF = mf.DoF
+		res.RedChi2 = jsonFloat(mf.RedChi2)
+		if residuals {
+			res.Residuals = toJsonFloats(mf.Residuals)
+		}
+		res.AIC = jsonFloat(mf.AIC)
+		res.BIC = jsonFloat(mf.BIC)
+		res.Converged = mf.Converged
+		res.Iterations = mf.Iterations
+		res.ResidualNorm = jsonFloat(mf.ResidualNorm)
+		res.Status = mf.Message
+		res.SE = toJsonFloats(mf.SE)
+		if profileCI {
+			lower, upper := m.ProfileCI(xdata, ydata, sedata, mf.Result, 0.95)
+			res.CILower = toJsonFloats(lower)
+			res.CIUpper = toJsonFloats(upper)
+		}
+		return
+	}
+}
+
+// jsonFloat is a float64 encoded as null in JSON if it is NaN or infinite,
+// which JSON does not support.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v)
+}
+
+func (f *jsonFloat) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*f = jsonFloat(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+func toJsonFloats(values []float64) (floats []jsonFloat) {
+	for _, v := range values {
+		floats = append(floats, jsonFloat(v))
+	}
 	return
 }
 
-func fitExp(xdata, ydata []float64) (res FitResult) {
-	par := fit.FitExp(xdata, ydata)
-	res.B0 = par[0]
-	res.B1 = par[1]
-	res.B2 = par[2]
+func fromJsonFloats(floats []jsonFloat) (values []float64) {
+	for _, v := range floats {
+		values = append(values, float64(v))
+	}
 	return
 }
 
+// ModelSelection summarizes information criteria of a model
+// over bootstrap fits.
+type ModelSelection struct {
+	Model     string
+	N         int     // number of fits.
+	AIC, BIC  float64 // mean AIC and BIC.
+	BestByAIC bool
+	BestByBIC bool
+}
+
+func selectModel(name string, fitResults []FitResult) (sel ModelSelection) {
+	sel.Model = name
+	for _, res := range fitResults {
+		sel.AIC += float64(res.AIC)
+		sel.BIC += float64(res.BIC)
+		sel.N++
+	}
+	if sel.N > 0 {
+		sel.AIC /= float64(sel.N)
+		sel.BIC /= float64(sel.N)
+	}
+	return
+}
+
+// Mark models with the smallest mean AIC and BIC.
+func markBestModels(selections []ModelSelection) {
+	bestAIC, bestBIC := -1, -1
+	for i, sel := range selections {
+		if sel.N == 0 {
+			continue
+		}
+		if bestAIC < 0 || sel.AIC < selections[bestAIC].AIC {
+			bestAIC = i
+		}
+		if bestBIC < 0 || sel.BIC < selections[bestBIC].BIC {
+			bestBIC = i
+		}
+	}
+	if bestAIC >= 0 {
+		selections[bestAIC].BestByAIC = true
+		selections[bestBIC].BestByBIC = true
+	}
+}
+
+func saveModelSelections(filePath string, selections []ModelSelection) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	e := json.NewEncoder(f)
+	if err := e.Encode(selections); err != nil {
+		panic(err)
+	}
+}
+
+// Number of site pairs at the i-th lag of Ct,
+// or one if CtN is not available (unweighted).
+func ctN(r CovResult, i int) int {
+	if len(r.CtN) != len(r.Ct) {
+		return 1
+	}
+	return r.CtN[i]
+}
+
+// Standard errors of Ct at the given positions of r,
+// from the variance of products of substitution indicators of site pairs,
+// whose mean is Ct + MeanXY.
+// Without MeanXY, they are relative standard errors from numbers of site pairs.
+func ctStdErrs(r CovResult, indices []int) []float64 {
+	p := []float64{}
+	n := []int{}
+	for _, i := range indices {
+		n = append(n, ctN(r, i))
+		if len(r.MeanXY) == len(r.Ct) {
+			p = append(p, r.Ct[i]+r.MeanXY[i])
+		}
+	}
+	if len(p) != len(n) {
+		return fit.StdErrFromCount(n)
+	}
+	return fit.StdErrFromProportion(p, n)
+}
+
 func isNaN(res FitResult) bool {
-	floats := []float64{}
+	floats := []jsonFloat{}
 	floats = append(floats, res.Ks)
 	floats = append(floats, res.B1)
 	floats = append(floats, res.B0)
 	floats = append(floats, res.B2)
+	floats = append(floats, res.Chi2)
+	floats = append(floats, res.RedChi2)
+	floats = append(floats, res.Residuals...)
+	floats = append(floats, res.Params...)
+	floats = append(floats, res.AIC, res.BIC, res.ResidualNorm)
+	return anyNaN(floats...)
+}
 
-	for _, v := range floats {
-		if math.IsNaN(v) {
+// Check if any of values is NaN or infinite.
+func anyNaN(values ...jsonFloat) bool {
+	for _, v := range values {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
 			return true
 		}
 	}
-
 	return false
 }
 
@@ -237,20 +617,31 @@ func fromJson(filePath string) (resChan chan CovResult) {
 	return
 }
 
-func toJson(filePath string, fitResChan chan FitResult) {
+func toJson(filePath string, fitResChan chan FitResult) (fitResults []FitResult) {
 	f, err := os.Create(filePath)
 	if err != nil {
 		panic(err)
 	}
 	defer f.Close()
 
-	fitResults := []FitResult{}
+	fitResults = []FitResult{}
 	for res := range fitResChan {
 		fitResults = append(fitResults, res)
 	}
+	// fits arrive in the order of workers finishing.
+	sort.Sort(byBoot(fitResults))
 
 	e := json.NewEncoder(f)
 	if err := e.Encode(fitResults); err != nil {
 		panic(err)
 	}
+
+	return
 }
+
+// byBoot sorts fit results by indices of bootstrap replicates.
+type byBoot []FitResult
+
+func (b byBoot) Len() int           { return len(b) }
+func (b byBoot) Less(i, j int) bool { return b[i].Boot < b[j].Boot }
+func (b byBoot) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mingzhi/meta/fit"
+	"github.com/mingzhi/meta/strain"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// JointStrainFit contains estimates of a strain in a joint fit.
+type JointStrainFit struct {
+	Strain string
+	Genome string
+	Params []jsonFloat // including shared parameters.
+	SE     []jsonFloat
+}
+
+// JointFitResult is the result of fitting a model jointly
+// to all strains of a species, sharing selected parameters.
+type JointFitResult struct {
+	Species  string
+	AlnType  string
+	FuncType string
+	Pos      int
+	Model    string
+	Params   []string // names of parameters.
+
+	Shared       []string
+	SharedParams []jsonFloat
+	SharedSE     []jsonFloat
+	Strains      []JointStrainFit
+
+	Converged  bool
+	Iterations int
+	Status     string
+	Chi2       jsonFloat
+	RedChi2    jsonFloat
+	AIC, BIC   jsonFloat
+}
+
+// Command to fit models jointly to all strains of a species,
+// after cov_genomes.
+type cmdFitJoint struct {
+	cmdConfig
+}
+
+func (cmd *cmdFitJoint) Init() {
+	// Parse config and settings.
+	cmd.ParseConfig()
+	// Load species map.
+	cmd.LoadSpeciesMap()
+	// Make output directory.
+	MakeDir(filepath.Join(*cmd.workspace, cmd.fitOutBase))
+	// Check profile positions.
+	if len(cmd.positions) == 0 {
+		WARN.Println("Use default position: 4!")
+		cmd.positions = append(cmd.positions, 4)
+	}
+}
+
+func (cmd *cmdFitJoint) Run(args []string) {
+	cmd.Init()
+	if len(cmd.fitJointModels) == 0 {
+		WARN.Println("No joint fit models in fit.joint.models!")
+		return
+	}
+	for prefix, strains := range cmd.speciesMap {
+		for _, pos := range cmd.positions {
+			for _, alnType := range cmd.corePolicy.Types() {
+				for _, funcType := range []string{"Cov_Genomes_vs_Genome", "Cov_Genomes_vs_Genomes"} {
+					cmd.jointFit(prefix, strains, pos, alnType, funcType)
+				}
+			}
+		}
+	}
+}
+
+// Fit configured models jointly to mean profiles of all strains of a species.
+func (cmd *cmdFitJoint) jointFit(prefix string, strains []strain.Strain, pos int, alnType, funcType string) {
+	for _, modelName := range cmd.fitJointModels {
+		m, found := fit.Lookup(modelName)
+		if !found {
+			WARN.Printf("Unknown joint fit model: %s\n", modelName)
+			continue
+		}
+		var fitCon fitControl
+		for _, fc := range cmd.fitControls {
+			if fc.name == modelName {
+				fitCon = fc
+			}
+		}
+		if fitCon.end-fitCon.start <= 0 {
+			WARN.Printf("No fit range for joint fit model: %s\n", modelName)
+			continue
+		}
+
+		datasets := []fit.Dataset{}
+		strainFits := []JointStrainFit{}
+		for _, s := range strains {
+			for _, g := range s.Genomes {
+				filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", g.RefAcc(), funcType, alnType, pos)
+				filePath := filepath.Join(*cmd.workspace, cmd.covOutBase, s.Path, filePrefix+"_boot.json.zip")
+				if _, err := os.Stat(filePath); os.IsNotExist(err) {
+					continue
+				}
+				ds := meanProfile(fromJson(filePath), fitCon.start, fitCon.end)
+				if len(ds.T) == 0 {
+					continue
+				}
+				datasets = append(datasets, ds)
+				strainFits = append(strainFits, JointStrainFit{Strain: s.Path, Genome: g.RefAcc()})
+			}
+		}
+		if len(datasets) == 0 {
+			continue
+		}
+
+		res, err := m.JointFit(datasets, cmd.fitJointShared)
+		if err != nil {
+			WARN.Printf("%s: %v\n", prefix, err)
+			continue
+		}
+		jr := JointFitResult{Species: prefix, AlnType: alnType, FuncType: funcType, Pos: pos, Model: modelName}
+		jr.Params = m.Params
+		jr.Shared = res.Shared
+		jr.SharedParams = toJsonFloats(res.SharedParams)
+		jr.SharedSE = toJsonFloats(res.SharedSE)
+		for i := range strainFits {
+			strainFits[i].Params = toJsonFloats(res.Params[i])
+			strainFits[i].SE = toJsonFloats(res.SE[i])
+		}
+		jr.Strains = strainFits
+		jr.Converged = res.Converged
+		jr.Iterations = res.Iterations
+		jr.Status = res.Message
+		jr.Chi2 = jsonFloat(res.Chi2)
+		jr.RedChi2 = jsonFloat(res.RedChi2)
+		jr.AIC = jsonFloat(res.AIC)
+		jr.BIC = jsonFloat(res.BIC)
+
+		filePrefix := fmt.Sprintf("%s_%s_%s_pos%d", prefix, funcType, alnType, pos)
+		filePath := filepath.Join(*cmd.workspace, cmd.fitOutBase, filePrefix+"_"+modelName+"_joint.json")
+		saveSummary(filePath, jr)
+	}
+}
+
+// meanProfile returns the mean Ct over bootstrap replicates in the fitting range,
+// with the standard deviations of the replicates as standard errors,
+// since the spread of bootstrap replicates estimates the error of Ct itself.
+func meanProfile(resChan chan CovResult, fitStart, fitEnd int) (ds fit.Dataset) {
+	sums := make(map[int]float64)
+	sumSqs := make(map[int]float64)
+	ns := make(map[int]int)
+	maxIndex := -1
+	for r := range resChan {
+		for i, l := range r.CtIndices {
+			if l >= fitStart && l < fitEnd && !math.IsNaN(r.Ct[i]) {
+				sums[l] += r.Ct[i]
+				sumSqs[l] += r.Ct[i] * r.Ct[i]
+				ns[l]++
+				if l > maxIndex {
+					maxIndex = l
+				}
+			}
+		}
+	}
+
+	variances := []float64{}
+	// one observation of each variance, which is the squared error itself,
+	// or none without replicates.
+	counts := []int{}
+	for l := fitStart; l <= maxIndex; l++ {
+		n := ns[l]
+		if n == 0 {
+			continue
+		}
+		mean := sums[l] / float64(n)
+		v := math.NaN()
+		if n > 1 {
+			v = (sumSqs[l]/float64(n) - mean*mean) * float64(n) / float64(n-1)
+		}
+		ds.T = append(ds.T, float64(l))
+		ds.Y = append(ds.Y, mean)
+		variances = append(variances, v)
+		if n > 1 {
+			counts = append(counts, 1)
+		} else {
+			counts = append(counts, 0)
+		}
+	}
+
+	ds.SE = fit.StdErrFromVar(variances, counts)
+	for _, se := range ds.SE {
+		if math.IsInf(se, 1) {
+			// unweighted without replicates.
+			ds.SE = nil
+			break
+		}
+	}
+	return
+}
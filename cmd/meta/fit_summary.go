@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/mingzhi/meta/fit"
+	"math"
+	"os"
+	"sort"
+)
+
+// ParamSummary summarizes a parameter over bootstrap fits.
+type ParamSummary struct {
+	Name   string
+	N      int
+	Median jsonFloat
+	Mean   jsonFloat
+	SD     jsonFloat
+	P025   jsonFloat // 2.5% percentile.
+	P975   jsonFloat // 97.5% percentile.
+}
+
+// FitSummary summarizes bootstrap fits of a genome.
+type FitSummary struct {
+	Genome   string
+	AlnType  string // core, disp or pan.
+	FuncType string
+	Pos      int
+	Model    string
+	NBoot    int // number of bootstrap fits.
+	Params   []ParamSummary
+}
+
+// SpeciesFitSummary combines bootstrap fits of strains of a species.
+type SpeciesFitSummary struct {
+	Species  string
+	AlnType  string
+	FuncType string
+	Pos      int
+	Model    string
+	NStrains int // number of genomes with fits.
+	// Pooled summarizes bootstrap fits of all strains;
+	// AcrossStrains summarizes medians of strains.
+	Pooled        []ParamSummary
+	AcrossStrains []ParamSummary
+}
+
+// fitParams returns names and values of parameters of a fit result,
+// including Ks.
+func fitParams(res FitResult) (names []string, values []float64) {
+	names = append(names, "Ks")
+	values = append(values, float64(res.Ks))
+	if r := res.Recomb; r != nil {
+		names = append(names, "Theta", "Phi", "Rho", "Ratio", "Fragment")
+		values = append(values, fromJsonFloats([]jsonFloat{r.Theta, r.Phi, r.Rho, r.Ratio, r.Fragment})...)
+		return
+	}
+
+	if m, found := fit.Lookup(res.Model); found && len(m.Params) == len(res.Params) {
+		names = append(names, m.Params...)
+		values = append(values, fromJsonFloats(res.Params)...)
+	} else {
+		names = append(names, "B0", "B1", "B2")
+		values = append(values, fromJsonFloats([]jsonFloat{res.B0, res.B1, res.B2})...)
+	}
+	return
+}
+
+// summarizeFits summarizes each parameter over fit results.
+func summarizeFits(fitResults []FitResult) (summaries []ParamSummary) {
+	var names []string
+	valueMap := make(map[string][]float64)
+	for _, res := range fitResults {
+		ns, vs := fitParams(res)
+		if names == nil {
+			names = ns
+		}
+		for i := range ns {
+			valueMap[ns[i]] = append(valueMap[ns[i]], vs[i])
+		}
+	}
+
+	for _, name := range names {
+		summaries = append(summaries, summarizeValues(name, valueMap[name]))
+	}
+	return
+}
+
+// summarizeValues calculates median, mean, SD and percentiles,
+// skipping NaN values.
+func summarizeValues(name string, values []float64) (s ParamSummary) {
+	s.Name = name
+	sorted := []float64{}
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Float64s(sorted)
+	s.N = len(sorted)
+
+	mean, sd := math.NaN(), math.NaN()
+	if s.N > 0 {
+		sum := 0.0
+		for _, v := range sorted {
+			sum += v
+		}
+		mean = sum / float64(s.N)
+	}
+	if s.N > 1 {
+		ss := 0.0
+		for _, v := range sorted {
+			ss += (v - mean) * (v - mean)
+		}
+		sd = math.Sqrt(ss / float64(s.N-1))
+	}
+
+	s.Mean = jsonFloat(mean)
+	s.SD = jsonFloat(sd)
+	s.Median = jsonFloat(percentile(sorted, 0.5))
+	s.P025 = jsonFloat(percentile(sorted, 0.025))
+	s.P975 = jsonFloat(percentile(sorted, 0.975))
+	return
+}
+
+// percentile of sorted values, by linear interpolation between closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	h := p * float64(len(sorted)-1)
+	i := int(math.Floor(h))
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (h-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// combineStrains summarizes fits of all strains of a species,
+// both pooled and across strain medians.
+func combineStrains(strainFits [][]FitResult) (pooled, acrossStrains []ParamSummary, nStrains int) {
+	all := []FitResult{}
+	var names []string
+	medians := make(map[string][]float64)
+	for _, fitResults := range strainFits {
+		if len(fitResults) == 0 {
+			continue
+		}
+		nStrains++
+		all = append(all, fitResults...)
+		for _, s := range summarizeFits(fitResults) {
+			if _, found := medians[s.Name]; !found {
+				names = append(names, s.Name)
+			}
+			medians[s.Name] = append(medians[s.Name], float64(s.Median))
+		}
+	}
+
+	pooled = summarizeFits(all)
+	for _, name := range names {
+		acrossStrains = append(acrossStrains, summarizeValues(name, medians[name]))
+	}
+	return
+}
+
+func saveSummary(filePath string, summary interface{}) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		ERROR.Panicln(err)
+	}
+	defer f.Close()
+
+	e := json.NewEncoder(f)
+	if err := e.Encode(summary); err != nil {
+		ERROR.Panicln(err)
+	}
+}
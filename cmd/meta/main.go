@@ -30,6 +30,9 @@ func main() {
 	command.On("scaffold_merge", "merge scaffolds", &cmdScaffoldMerge{}, args)
 	command.On("genome_profile", "genome position profiling", &cmdGenomeProfile{}, args)
 	command.On("fit_genomes", "fit genome cov results", &cmdFitGenomes{}, args)
+	command.On("fit_joint", "fit genome cov results jointly across strains", &cmdFitJoint{}, args)
+	command.On("scan", "scan diversity and correlation in sliding windows", &cmdScan{}, args)
+	command.On("simulate_reads", "simulate paired-end reads from reference strains", &cmdSimulateReads{}, args)
 
 	// Parse and run commands.
 	command.ParseAndRun()
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/mingzhi/biogo/seq"
 	"github.com/mingzhi/meta/align/multi"
 	"github.com/mingzhi/meta/genome"
@@ -30,34 +31,46 @@ func (cmd *cmdOrthoAln) Run(args []string) {
 		// Read ortholog protein clusters.
 		rawClusters := cmd.ReadOrhtologs(prefix)
 		// filter outliers based on their lengths.
+		// cluster IDs follow the order of ortho_mcl clusters.
 		clusters := []seqrecord.SeqRecords{}
+		ids := []string{}
+		problems := []clusterProblem{}
 		for i := 0; i < len(rawClusters); i++ {
-			records := rawClusters[i]
+			id := fmt.Sprintf("cluster_%d", i+1)
+			// check coding sequences, dropping or repairing bad records.
+			records, recordProblems := multi.ValidateRecords(rawClusters[i])
+			for _, p := range recordProblems {
+				problems = append(problems, clusterProblem{id, p})
+			}
 			if len(records) >= 3 {
-				cls := filter(rawClusters[i])
+				cls := filter(records)
 				if len(cls) == len(records) {
 					clusters = append(clusters, cls)
+					ids = append(ids, id)
 				}
 			}
 
 		}
+		cmd.writeCodingProblems(prefix, problems)
 
 		if len(clusters) > 0 {
 			// align coding regions (protein clusters).
-			alns := align(clusters, multi.AlignProt, *cmd.ncpu)
+			alns := cmd.align(clusters, ids, multi.AlignProt)
 			cmd.SaveAlignments(prefix, alns)
 
 			// expand gene to include its adjacent non-coding regions.
 			appendix := "expanded"
 			m := getGenomeMap(strains, cmd.refBase)
 			expandedClusters := []seqrecord.SeqRecords{}
-			for _, records := range clusters {
+			expandedIds := []string{}
+			for i, records := range clusters {
 				expandedRecords := expand(records, m)
 				if len(expandedRecords) > 0 {
 					expandedClusters = append(expandedClusters, filter(expandedRecords))
+					expandedIds = append(expandedIds, ids[i])
 				}
 			}
-			expandedAlns := align(expandedClusters, multi.AlignNucl, *cmd.ncpu)
+			expandedAlns := cmd.align(expandedClusters, expandedIds, multi.AlignNucl)
 			cmd.SaveAlignments(prefix, expandedAlns, appendix)
 		} else {
 			WARN.Printf("%s has zero orthologous cluster\n", prefix)
@@ -65,21 +78,71 @@ func (cmd *cmdOrthoAln) Run(args []string) {
 	}
 }
 
-type multiAlignFunc func(seqRecords []seqrecord.SeqRecord, alignFunc multi.AlignFunc, options ...string) []seqrecord.SeqRecord
+// clusterProblem is a coding problem of a record in a cluster.
+type clusterProblem struct {
+	cluster string
+	multi.CodingProblem
+}
+
+// writeCodingProblems writes coding problems of records,
+// and logs numbers of repaired and dropped records.
+func (cmd *cmdOrthoAln) writeCodingProblems(prefix string, problems []clusterProblem) {
+	fileName := prefix + "_coding_problems.tsv"
+	filePath := filepath.Join(*cmd.workspace, cmd.orthoOutBase, fileName)
+	f, err := os.Create(filePath)
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	defer f.Close()
+
+	repaired, dropped := 0, 0
+	f.WriteString("cluster\tid\tgenome\taction\tproblem\n")
+	for _, p := range problems {
+		action := "dropped"
+		if p.Repaired {
+			action = "repaired"
+			repaired++
+		} else {
+			dropped++
+		}
+		fmt.Fprintf(f, "%s\t%s\t%s\t%s\t%v\n", p.cluster, p.Id, p.Genome, action, p.Err)
+	}
+	if repaired+dropped > 0 {
+		WARN.Printf("%s: %d records repaired and %d dropped by coding checks, see %s\n", prefix, repaired, dropped, fileName)
+	}
+}
+
+type multiAlignFunc func(seqRecords []seqrecord.SeqRecord, alignFunc multi.AlignFunc, options ...string) ([]seqrecord.SeqRecord, error)
+
+// align clusters with the configured aligner,
+// skipping clusters failed to be aligned.
+func (cmd *cmdOrthoAln) align(clusters []seqrecord.SeqRecords, ids []string, alignFunc multiAlignFunc) (alns []seqrecord.SeqRecords) {
+	aligner, err := multi.NewAlignFunc(cmd.alignProgram)
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	options := cmd.alignOptions
+	if !multi.Available(cmd.alignProgram) {
+		WARN.Printf("Aligner %s is not available, use the builtin aligner!\n", cmd.alignProgram)
+		aligner, options = multi.Progressive, nil
+	}
 
-func align(clusters []seqrecord.SeqRecords, alignFunc multiAlignFunc, ncpu int) (alns []seqrecord.SeqRecords) {
 	// Create a job for each sequence records.
-	jobs := make(chan seqrecord.SeqRecords)
+	type job struct {
+		id      string
+		cluster seqrecord.SeqRecords
+	}
+	jobs := make(chan job)
 	go func() {
 		defer close(jobs)
-		for _, cluster := range clusters {
+		for i, cluster := range clusters {
 			if len(cluster) >= 3 {
-				jobs <- cluster
+				jobs <- job{ids[i], cluster}
 			}
 		}
 	}()
 
-	numWorker := ncpu
+	numWorker := *cmd.ncpu
 
 	// Create workers to do jobs.
 	// done is signal channel.
@@ -88,8 +151,12 @@ func align(clusters []seqrecord.SeqRecords, alignFunc multiAlignFunc, ncpu int)
 	results := make(chan seqrecord.SeqRecords)
 	for i := 0; i < numWorker; i++ {
 		go func() {
-			for cluster := range jobs {
-				aln := alignFunc(cluster, multi.Muscle)
+			for j := range jobs {
+				aln, err := alignFunc(j.cluster, aligner, options...)
+				if err != nil {
+					WARN.Printf("%s: %s\n", j.id, err)
+					continue
+				}
 				results <- aln
 			}
 			done <- true
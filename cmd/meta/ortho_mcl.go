@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/mingzhi/meta/ortho"
+	"github.com/mingzhi/meta/sim"
+	"github.com/mingzhi/meta/strain"
 	"github.com/mingzhi/ncbiftp/seqrecord"
 	"os"
 	"path/filepath"
@@ -20,16 +23,23 @@ func (cmd *cmdOrthoMCL) Run(args []string) {
 	cmd.ParseConfig()
 	cmd.LoadSpeciesMap()
 	MakeDir(filepath.Join(*cmd.workspace, cmd.orthoOutBase))
+	opts := cmd.orthoOptions()
 
 	for prefix, strains := range cmd.speciesMap {
 		if len(strains) >= 3 {
 			INFO.Printf("%s\n", prefix)
 			// OrthoMCL
-			clusters := ortho.OrthoMCl(strains, cmd.refBase)
+			clusters, err := ortho.OrthoMCl(strains, cmd.refBase, opts)
+			if err != nil {
+				ERROR.Fatalf("%s: %v\n", prefix, err)
+			}
 
 			// Write clusters into a file.
 			cmd.writeClusters(prefix, clusters)
 
+			// Write gene presence and absence, and pangenome summaries.
+			cmd.writePangenome(prefix, strains, clusters)
+
 			// Find ortholog sequences.
 			groups := ortho.FindOrthologs(strains, cmd.refBase, clusters)
 			cmd.writeOrthologs(prefix, groups)
@@ -41,6 +51,41 @@ func (cmd *cmdOrthoMCL) Run(args []string) {
 
 }
 
+// Return options of ortholog clustering,
+// with the homology search backends and parameters of the config.
+func (cmd *cmdOrthoMCL) orthoOptions() (opts ortho.Options) {
+	var err error
+	opts.Backend, err = ortho.NewSearchBackend(cmd.orthoSearch, cmd.orthoSearchOptions)
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	// Searches of genomes against themselves report more hits,
+	// since the top hit of each query is itself.
+	paralogOptions := cmd.orthoSearchOptions
+	paralogOptions.MaxTargets = cmd.orthoParalogTargets
+	opts.ParalogBackend, err = ortho.NewSearchBackend(cmd.orthoSearch, paralogOptions)
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	// Cache search hits, so that reruns skip completed searches.
+	if cmd.orthoCache != "" {
+		cacheDir := filepath.Join(*cmd.workspace, cmd.orthoOutBase, cmd.orthoCache)
+		cached, err := ortho.NewCachedBackend(opts.Backend, cacheDir)
+		if err != nil {
+			ERROR.Fatalln(err)
+		}
+		opts.Backend = cached
+		cached, err = ortho.NewCachedBackend(opts.ParalogBackend, cacheDir)
+		if err != nil {
+			ERROR.Fatalln(err)
+		}
+		opts.ParalogBackend = cached
+	}
+	opts.Edges = cmd.orthoEdgeParams
+	opts.MCL = cmd.orthoMCLParams
+	return
+}
+
 func (cmd *cmdOrthoMCL) writeClusters(prefix string, clusters [][]string) {
 	fileName := prefix + ".mcl"
 	filePath := filepath.Join(*cmd.workspace, cmd.orthoOutBase, fileName)
@@ -70,3 +115,84 @@ func (cmd *cmdOrthoMCL) writeOrthologs(prefix string, groups []seqrecord.SeqReco
 		ERROR.Fatalln(err)
 	}
 }
+
+// writePangenome writes the gene presence-absence matrix of clusters,
+// and singleton clusters of genes without edges,
+// in gene ids and in 0/1 (Rtab) as Roary,
+// counts of core, soft-core, shell and cloud clusters,
+// and pangenome accumulation curves.
+func (cmd *cmdOrthoMCL) writePangenome(prefix string, strains []strain.Strain, clusters [][]string) {
+	clusters, err := ortho.AddSingletons(clusters, strains, cmd.refBase)
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	matrix := ortho.PresenceAbsence(clusters, strains)
+	params := cmd.orthoPangenome
+	outDir := filepath.Join(*cmd.workspace, cmd.orthoOutBase)
+
+	header := []string{"cluster"}
+	for _, s := range strains {
+		header = append(header, s.Path)
+	}
+	genesFile, err := os.Create(filepath.Join(outDir, prefix+"_gene_presence_absence.tsv"))
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	defer genesFile.Close()
+	rtabFile, err := os.Create(filepath.Join(outDir, prefix+"_gene_presence_absence.Rtab"))
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	defer rtabFile.Close()
+
+	fmt.Fprintf(genesFile, "%s\tclass\tstrains\t%s\n", header[0], strings.Join(header[1:], "\t"))
+	rtabFile.WriteString(strings.Join(header, "\t") + "\n")
+	for i, row := range matrix {
+		name := fmt.Sprintf("cluster_%d", i+1)
+		n := ortho.StrainCount(row)
+		genes := []string{name, params.Classify(n, len(strains)), fmt.Sprintf("%d", n)}
+		presence := []string{name}
+		for _, names := range row {
+			genes = append(genes, strings.Join(names, ","))
+			if len(names) > 0 {
+				presence = append(presence, "1")
+			} else {
+				presence = append(presence, "0")
+			}
+		}
+		genesFile.WriteString(strings.Join(genes, "\t") + "\n")
+		rtabFile.WriteString(strings.Join(presence, "\t") + "\n")
+	}
+
+	// Summary of classes, with their thresholds.
+	summary := ortho.Summarize(matrix, len(strains), params)
+	summaryFile, err := os.Create(filepath.Join(outDir, prefix+"_pangenome_summary.json"))
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	defer summaryFile.Close()
+	encoder := json.NewEncoder(summaryFile)
+	err = encoder.Encode(struct {
+		Params  ortho.PangenomeParams
+		Summary ortho.PangenomeSummary
+	}{params, summary})
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	INFO.Printf("%s: %d clusters, %d core, %d soft core, %d shell, %d cloud\n",
+		prefix, summary.Clusters, summary.Core, summary.SoftCore, summary.Shell, summary.Cloud)
+
+	// Accumulation curves over random orders of strains.
+	rng := sim.NewJobRand(cmd.seed, prefix, "accumulation")
+	points := ortho.Accumulation(matrix, len(strains), cmd.orthoPermutations, rng)
+	curveFile, err := os.Create(filepath.Join(outDir, prefix+"_accumulation.tsv"))
+	if err != nil {
+		ERROR.Fatalln(err)
+	}
+	defer curveFile.Close()
+	fmt.Fprintf(curveFile, "# seed: %d\n", cmd.seed)
+	curveFile.WriteString("strains\tpan_mean\tpan_sd\tcore_mean\tcore_sd\n")
+	for _, p := range points {
+		fmt.Fprintf(curveFile, "%d\t%g\t%g\t%g\t%g\n", p.Strains, p.PanMean, p.PanSD, p.CoreMean, p.CoreSD)
+	}
+}
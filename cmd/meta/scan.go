@@ -0,0 +1,317 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mingzhi/meta/cov"
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/reads"
+	"github.com/mingzhi/meta/strain"
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Command to scan diversity and correlation along genomes,
+// in sliding windows.
+type cmdScan struct {
+	source    string // source of data: reads or alignments.
+	cmdConfig        // embed cmdConfig
+
+	window int // window size.
+	step   int // step size between windows.
+}
+
+func (cmd *cmdScan) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	fs = cmd.cmdConfig.Flags(fs)
+	fs.StringVar(&cmd.source, "source", "reads", "source of data: reads or alignments")
+	return fs
+}
+
+func (cmd *cmdScan) Init() {
+	// Parse config and settings.
+	cmd.ParseConfig()
+	// Load species map.
+	cmd.LoadSpeciesMap()
+	// Make output directory.
+	MakeDir(filepath.Join(*cmd.workspace, cmd.covOutBase))
+	// Check profile positions.
+	if len(cmd.positions) == 0 {
+		WARN.Println("Use default position: 4!")
+		cmd.positions = append(cmd.positions, 4)
+	}
+	// Check window and step sizes.
+	cmd.window = cmd.scanWindowSize
+	cmd.step = cmd.scanStepSize
+	if cmd.window <= 0 {
+		WARN.Println("Use default window size: 10000!")
+		cmd.window = 10000
+	}
+	if cmd.step <= 0 {
+		cmd.step = cmd.window
+	}
+}
+
+// Run command.
+func (cmd *cmdScan) Run(args []string) {
+	cmd.Init()
+
+	for prefix, strains := range cmd.speciesMap {
+		switch cmd.source {
+		case "reads":
+			for _, s := range strains {
+				cmd.scanReads(s)
+			}
+		case "alignments":
+			for _, pos := range cmd.positions {
+				p := prefix
+				if pos == 0 {
+					p = prefix + "_expanded"
+				}
+				alignments := cmd.readAlignments(p)
+				if len(alignments) == 0 {
+					WARN.Printf("%s has zero alignments\n", p)
+					continue
+				}
+				for _, s := range strains {
+					cmd.scanAlignments(s, alignments, pos)
+				}
+			}
+		default:
+			ERROR.Fatalf("Unknown source: %s\n", cmd.source)
+		}
+	}
+}
+
+// Result of a window.
+type windowResult struct {
+	Start, End int     // genome coordinates, 0-based and half-open.
+	Depth      float64 // mean read depth within the window, or number of alignments.
+	NReads     int     // number of reads or alignments.
+	Res        CovResult
+}
+
+// Scan windows with mapped reads.
+func (cmd *cmdScan) scanReads(s strain.Strain) {
+	for _, g := range s.Genomes {
+		if !isChromosome(g.Replicon) {
+			continue
+		}
+
+		samFilePath := filepath.Join(*cmd.workspace, cmd.samOutBase, s.Path, g.RefAcc()+bowtiedSamAppendix)
+		if !isSamFileExist(samFilePath) {
+			continue
+		}
+		_, records := reads.ReadSamFile(samFilePath)
+		if len(records) == 0 {
+			WARN.Printf("%s,%s has zero records\n", s.Path, g.RefAcc())
+			continue
+		}
+
+		base := filepath.Join(cmd.refBase, s.Path)
+		genome.LoadFna(&g, base)
+		genome.LoadProfile(&g, base)
+
+		matedReads := reads.GetPairedEndReads(records)
+		sort.Sort(reads.ByLeftCoordinatePairedEndReads{PairedEndReads: matedReads})
+		maxSpan := 0
+		for _, r := range matedReads {
+			maxSpan = maxInt(maxSpan, r.ReadRight.Pos+r.ReadRight.Len()-r.ReadLeft.Pos)
+		}
+
+		for _, pos := range cmd.positions {
+			windows := []windowResult{}
+			for start := 0; start < len(g.Seq); start += cmd.step {
+				end := minInt(start+cmd.window, len(g.Seq))
+				i := sort.Search(len(matedReads), func(i int) bool { return matedReads[i].ReadLeft.Pos >= start })
+				j := sort.Search(len(matedReads), func(i int) bool { return matedReads[i].ReadLeft.Pos >= end })
+				subset := matedReads[i:j]
+
+				w := windowResult{Start: start, End: end, NReads: len(subset)}
+				w.Depth = windowDepth(matedReads, start, end, maxSpan)
+
+				if len(subset) > 0 {
+					kc, cc := cov.ReadsVsGenome(subset, g, cmd.maxl, pos)
+					w.Res = createReadsCovResult(kc, cc, scanStep(pos), cmd.maxl/scanStep(pos))
+				} else {
+					w.Res.Ks = math.NaN()
+				}
+				windows = append(windows, w)
+			}
+
+			filePrefix := fmt.Sprintf("%s_scan_reads_pos%d", g.RefAcc(), pos)
+			cmd.writeScan(s.Path, g.RefAcc(), filePrefix, windows, scanStep(pos))
+		}
+	}
+}
+
+// Scan windows with ortholog alignments,
+// whose genes in the genome start in the window.
+func (cmd *cmdScan) scanAlignments(s strain.Strain, alignments []seqrecord.SeqRecords, pos int) {
+	for _, g := range s.Genomes {
+		if !isChromosome(g.Replicon) {
+			continue
+		}
+
+		base := filepath.Join(cmd.refBase, s.Path)
+		genome.LoadFna(&g, base)
+		genome.LoadProfile(&g, base)
+
+		// locate alignments on the genome.
+		type located struct {
+			start int
+			aln   seqrecord.SeqRecords
+		}
+		locs := []located{}
+		for _, aln := range alignments {
+			for _, rec := range aln {
+				if genome.FindRefAcc(rec.Genome) == g.RefAcc() {
+					locs = append(locs, located{rec.Loc.From - 1, aln})
+					break
+				}
+			}
+		}
+		sort.Slice(locs, func(i, j int) bool { return locs[i].start < locs[j].start })
+
+		windows := []windowResult{}
+		for start := 0; start < len(g.Seq); start += cmd.step {
+			end := minInt(start+cmd.window, len(g.Seq))
+			i := sort.Search(len(locs), func(i int) bool { return locs[i].start >= start })
+			j := sort.Search(len(locs), func(i int) bool { return locs[i].start >= end })
+			subset := []seqrecord.SeqRecords{}
+			for _, l := range locs[i:j] {
+				subset = append(subset, l.aln)
+			}
+
+			w := windowResult{Start: start, End: end, NReads: len(subset)}
+			w.Depth = float64(len(subset))
+			if len(subset) > 0 {
+				cc := cov.GenomesCalc(subset, g, cmd.maxl, pos, cov.GenomesVsGenomesOne)
+				w.Res = createCovResult(cc, cmd.maxl, pos)
+			} else {
+				w.Res.Ks = math.NaN()
+			}
+			windows = append(windows, w)
+		}
+
+		filePrefix := fmt.Sprintf("%s_scan_alignments_pos%d", g.RefAcc(), pos)
+		cmd.writeScan(s.Path, g.RefAcc(), filePrefix, windows, scanStep(pos))
+	}
+}
+
+// Write windows into a TSV file,
+// and bedGraph tracks of Ks, depth and correlation decay,
+// in which step is the number of bases per lag index of correlations.
+func (cmd *cmdScan) writeScan(strainPath, chrom, filePrefix string, windows []windowResult, step int) {
+	dir := filepath.Join(*cmd.workspace, cmd.covOutBase, strainPath)
+	MakeDir(dir)
+
+	tsvFile := createFile(filepath.Join(dir, filePrefix+".tsv"))
+	defer tsvFile.Close()
+	tsvFile.WriteString("chrom\tstart\tend\tdepth\tn\tks\tvar_ks\tct1\thalf_decay\n")
+
+	metrics := []string{"ks", "depth", "half_decay"}
+	tracks := make(map[string]*os.File)
+	for _, m := range metrics {
+		f := createFile(filepath.Join(dir, filePrefix+"_"+m+".bedGraph"))
+		defer f.Close()
+		f.WriteString(fmt.Sprintf("track type=bedGraph name=\"%s %s\"\n", filePrefix, m))
+		tracks[m] = f
+	}
+
+	for _, w := range windows {
+		ct1, halfDecay := decaySummary(w.Res, step)
+		tsvFile.WriteString(fmt.Sprintf("%s\t%d\t%d\t%g\t%d\t%g\t%g\t%g\t%g\n",
+			chrom, w.Start, w.End, w.Depth, w.NReads, w.Res.Ks, w.Res.VarKs, ct1, halfDecay))
+
+		values := map[string]float64{"ks": w.Res.Ks, "depth": w.Depth, "half_decay": halfDecay}
+		for _, m := range metrics {
+			// bedGraph does not allow missing values.
+			if !math.IsNaN(values[m]) {
+				tracks[m].WriteString(fmt.Sprintf("%s\t%d\t%d\t%g\n", chrom, w.Start, w.End, values[m]))
+			}
+		}
+	}
+}
+
+// Summarize the decay of correlations:
+// the correlation at the smallest positive lag,
+// and the first lag in bp at which it drops below the half,
+// given the number of bases per lag index.
+func decaySummary(res CovResult, step int) (ct1, halfDecay float64) {
+	ct1, halfDecay = math.NaN(), math.NaN()
+	for i, l := range res.CtIndices {
+		if l == 0 {
+			continue
+		}
+		if math.IsNaN(ct1) {
+			ct1 = res.Ct[i]
+		} else if res.Ct[i] < ct1/2 {
+			halfDecay = float64(l * step)
+			break
+		}
+	}
+	return
+}
+
+// Return the mean read depth in a window [start, end),
+// counting mapped bases of reads within the window only.
+// matedReads are sorted by left coordinate,
+// and none of them spans more than maxSpan bases.
+func windowDepth(matedReads reads.PairedEndReads, start, end, maxSpan int) float64 {
+	i := sort.Search(len(matedReads), func(i int) bool { return matedReads[i].ReadLeft.Pos >= start-maxSpan })
+	bases := 0
+	for ; i < len(matedReads) && matedReads[i].ReadLeft.Pos < end; i++ {
+		r := matedReads[i]
+		read := reads.MapMated2Ref(r)
+		from := maxInt(start, r.ReadLeft.Pos)
+		to := minInt(end, r.ReadLeft.Pos+len(read))
+		for p := from; p < to; p++ {
+			if read[p-r.ReadLeft.Pos] != '*' {
+				bases++
+			}
+		}
+	}
+	return float64(bases) / float64(end-start)
+}
+
+// To use base distiance (step = 1) or codon distance (step = 3)
+func scanStep(pos int) int {
+	if pos == 0 {
+		return 1
+	}
+	return 3
+}
+
+// Load alignments.
+func (cmd *cmdScan) readAlignments(prefix string) []seqrecord.SeqRecords {
+	c := cmdCovGenomes{cmdConfig: cmd.cmdConfig}
+	return c.ReadAlignments(prefix)
+}
+
+// Create a file, or panic.
+func createFile(filePath string) *os.File {
+	f, err := os.Create(filePath)
+	if err != nil {
+		ERROR.Panicln(err)
+	}
+	return f
+}
+
+// return min int
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// return max int
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/sim"
+	"github.com/mingzhi/meta/strain"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// Command to simulate metagenomic paired-end reads
+// from reference strains of known relative abundances.
+type cmdSimulateReads struct {
+	cmdConfig // embed cmdConfig
+
+	abundances map[string]float64 // strain path: relative abundance.
+}
+
+func (cmd *cmdSimulateReads) Init() {
+	// Parse config and settings.
+	cmd.ParseConfig()
+	// Load species map.
+	cmd.LoadSpeciesMap()
+	// Make output directory.
+	MakeDir(filepath.Join(*cmd.workspace, cmd.samOutBase))
+	// Check read settings.
+	if cmd.simReadLength <= 0 {
+		WARN.Println("Use default read length: 100!")
+		cmd.simReadLength = 100
+	}
+	if cmd.simInsertSize < cmd.simReadLength {
+		WARN.Printf("Use default insert size: %d!\n", 3*cmd.simReadLength)
+		cmd.simInsertSize = 3 * cmd.simReadLength
+	}
+	// Read relative abundances.
+	cmd.abundances = cmd.readAbundances()
+}
+
+// A genome of a strain from which reads are simulated.
+type readSource struct {
+	prefix    string // species.
+	s         strain.Strain
+	g         genome.Genome
+	index     int     // index of the genome in genomes of the strain.
+	abundance float64 // relative abundance of the strain.
+	weight    float64 // abundance times genome length.
+}
+
+// Run command.
+func (cmd *cmdSimulateReads) Run(args []string) {
+	cmd.Init()
+
+	// genomes of strains with positive abundances.
+	sources := []readSource{}
+	totalWeight := 0.0
+	prefixes := []string{}
+	for prefix := range cmd.speciesMap {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		for _, s := range cmd.speciesMap[prefix] {
+			a := cmd.abundances[s.Path]
+			if a <= 0 {
+				continue
+			}
+			for i, g := range s.Genomes {
+				genome.LoadFna(&g, filepath.Join(cmd.refBase, s.Path))
+				if len(g.Seq) < cmd.simReadLength {
+					WARN.Printf("%s,%s is shorter than reads, skipped\n", s.Path, g.RefAcc())
+					continue
+				}
+				w := a * float64(len(g.Seq))
+				sources = append(sources, readSource{prefix, s, g, i, a, w})
+				totalWeight += w
+			}
+		}
+	}
+	if len(sources) == 0 {
+		ERROR.Fatalln("No strains with positive abundances!")
+	}
+
+	w1, w2 := createFile(cmd.pairedEndReadFile1), createFile(cmd.pairedEndReadFile2)
+	defer w1.Close()
+	defer w2.Close()
+	bw1, bw2 := bufio.NewWriter(w1), bufio.NewWriter(w2)
+	defer bw1.Flush()
+	defer bw2.Flush()
+
+	INFO.Printf("Random seed: %d\n", cmd.seed)
+	profile := sim.LinearErrorProfile(cmd.simReadLength, cmd.simErrorFirst, cmd.simErrorLast)
+	for _, src := range sources {
+		numPairs := int(float64(cmd.simNumPairs) * src.weight / totalWeight)
+		INFO.Printf("Simulate %d read pairs from %s, %s\n", numPairs, src.s.Path, src.g.RefAcc())
+		rng := sim.NewJobRand(cmd.seed, src.s.Path, src.g.RefAcc())
+		for i := 0; i < numPairs; i++ {
+			name := fmt.Sprintf("%s_%d", src.g.RefAcc(), i+1)
+			r1, r2, err := sim.PairedFastq(name, src.g.Seq, cmd.simInsertSize, cmd.simInsertSD, profile, rng)
+			if err != nil {
+				ERROR.Panicln(err)
+			}
+			if err := r1.Write(bw1); err != nil {
+				ERROR.Panicln(err)
+			}
+			if err := r2.Write(bw2); err != nil {
+				ERROR.Panicln(err)
+			}
+		}
+	}
+
+	for _, prefix := range prefixes {
+		cmd.writeTruth(prefix, sources)
+	}
+}
+
+// Read relative abundances of strains from a YAML file,
+// in which strain.path: abundance.
+func (cmd *cmdSimulateReads) readAbundances() map[string]float64 {
+	filePath := filepath.Join(*cmd.workspace, cmd.simAbundanceFile)
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		ERROR.Fatalf("Cannot read file %s: %v\n", filePath, err)
+	}
+
+	m := make(map[string]float64)
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		ERROR.Fatalf("Cannot unmarshal %s: %v\n", filePath, err)
+	}
+	return m
+}
+
+// Write expected allele frequencies of reads at sites of each genome of a species,
+// from genomes of its strains weighted by their abundances.
+// The i-th genomes of strains are compared site by site,
+// which requires them to be collinear and of equal lengths, as simulated genomes are.
+// Each row is genome, 1-based position, frequencies of A, C, G and T,
+// and the total abundance of strains with valid bases at the site.
+// The random seed is written to a sidecar .seed file.
+func (cmd *cmdSimulateReads) writeTruth(prefix string, sources []readSource) {
+	replicons := make(map[int][]readSource)
+	indices := []int{}
+	for _, src := range sources {
+		if src.prefix != prefix {
+			continue
+		}
+		if _, found := replicons[src.index]; !found {
+			indices = append(indices, src.index)
+		}
+		replicons[src.index] = append(replicons[src.index], src)
+	}
+	if len(indices) == 0 {
+		return
+	}
+	sort.Ints(indices)
+
+	filePath := filepath.Join(*cmd.workspace, cmd.samOutBase, prefix+"_allele_frequencies.tsv")
+	f := createFile(filePath)
+	defer f.Close()
+	seedFile := createFile(filePath + ".seed")
+	fmt.Fprintf(seedFile, "%d\n", cmd.seed)
+	seedFile.Close()
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	fmt.Fprintf(w, "genome\tpos\tA\tC\tG\tT\tabundance\n")
+
+	for _, i := range indices {
+		srcs := replicons[i]
+		freqs, totals, ok := siteFrequencies(srcs)
+		if !ok {
+			WARN.Printf("%s: genomes %d of strains differ in length, no allele frequencies\n", prefix, i+1)
+			continue
+		}
+		for _, src := range srcs {
+			for pos := range freqs {
+				if totals[pos] == 0 {
+					continue
+				}
+				fr := freqs[pos]
+				fmt.Fprintf(w, "%s\t%d\t%g\t%g\t%g\t%g\t%g\n", src.g.RefAcc(), pos+1,
+					fr[0], fr[1], fr[2], fr[3], totals[pos])
+			}
+		}
+	}
+}
+
+// Abundance-weighted frequencies of A, C, G and T at each site of genomes,
+// and total abundances of valid bases.
+// It returns false if genomes differ in length.
+func siteFrequencies(sources []readSource) (freqs [][4]float64, totals []float64, ok bool) {
+	L := len(sources[0].g.Seq)
+	for _, src := range sources {
+		if len(src.g.Seq) != L {
+			return
+		}
+	}
+
+	freqs = make([][4]float64, L)
+	totals = make([]float64, L)
+	for _, src := range sources {
+		for pos, b := range src.g.Seq {
+			if j := bytes.IndexByte([]byte("ACGT"), upper(b)); j >= 0 {
+				freqs[pos][j] += src.abundance
+				totals[pos] += src.abundance
+			}
+		}
+	}
+	for pos := range freqs {
+		for j := range freqs[pos] {
+			if totals[pos] > 0 {
+				freqs[pos][j] /= totals[pos]
+			}
+		}
+	}
+	ok = true
+	return
+}
+
+func upper(b byte) byte {
+	if b >= 'a' && b <= 'z' {
+		return b - 'a' + 'A'
+	}
+	return b
+}
@@ -22,6 +22,15 @@ type CovResult struct {
 	Cr        []float64
 	CrIndices []int
 	CrN       []int
+
+	// Sequencing-error corrected results.
+	ErrRate     float64
+	KsCorrected float64
+	CtCorrected []float64
+
+	// Random seed and index of bootstrap replicates.
+	Seed int64 `json:",omitempty"`
+	Boot int   `json:",omitempty"`
 }
 
 func MakeDir(d string) {
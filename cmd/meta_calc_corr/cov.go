@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/mingzhi/gomath/stat/desc/meanvar"
+	"github.com/mingzhi/meta/cov"
 )
 
 // Covariance contains cov structure
@@ -176,12 +177,17 @@ func covPairedBases(pairs []basePair) (c *Covariance) {
 		for j := i + 1; j < len(pairs); j++ {
 			p2 := pairs[j]
 			var x, y float64
-			if p1.a.Base != p2.a.Base {
-				x = 1.0
-			}
-
-			if p1.b.Base != p2.b.Base {
-				y = 1.0
+			if qualWeighted {
+				x = diffProb(p1.a, p2.a)
+				y = diffProb(p1.b, p2.b)
+			} else {
+				if p1.a.Base != p2.a.Base {
+					x = 1.0
+				}
+
+				if p1.b.Base != p2.b.Base {
+					y = 1.0
+				}
 			}
 
 			c.Increment(x, y)
@@ -190,6 +196,14 @@ func covPairedBases(pairs []basePair) (c *Covariance) {
 	return c
 }
 
+// diffProb returns the posterior probability of a true difference
+// between two bases, given their base qualities.
+func diffProb(a, b *Base) float64 {
+	e1 := cov.PhredErr(a.Qual)
+	e2 := cov.PhredErr(b.Qual)
+	return cov.DiffProb(a.Base != b.Base, e1, e2, subPrior)
+}
+
 // basePair is a pair of bases, which come from the same read (or paired-end).
 type basePair struct {
 	a, b *Base
@@ -34,6 +34,8 @@ var (
 	minLength    int
 	cpuprofile   string
 	ncpu         int
+	qualWeighted bool    // use posterior probabilities of differences.
+	subPrior     float64 // prior probability of a true difference.
 )
 
 func init() {
@@ -46,6 +48,8 @@ func init() {
 	flag.IntVar(&minLength, "min-length", 0, "Minimum read length")
 	flag.IntVar(&ncpu, "ncpu", runtime.NumCPU(), "number of cpus")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	flag.BoolVar(&qualWeighted, "qual-weighted", false, "Use posterior probabilities of differences given base qualities, instead of the min-BQ cutoff")
+	flag.Float64Var(&subPrior, "sub-prior", 0.01, "Prior probability of a true difference, used with -qual-weighted")
 	flag.Parse()
 	if flag.NArg() < 4 {
 		fmt.Println("meta_calc_corr <bam file> <ref genome sequence> <protein feature file> <output file>")
@@ -114,11 +114,12 @@ func clearSNPMap(m map[int]*SNP, minPos int) (snps []*SNP) {
 
 // FilterSNP filters low quality bases,
 // and overlapped bases in the pair-end reads.
+// Low quality bases are kept if qualWeighted is set.
 func FilterSNP(s *SNP) *SNP {
 	m := make(map[string]*Base)
 	for i := 0; i < len(s.Bases); i++ {
 		b := s.Bases[i]
-		if int(b.Qual) >= minBQ {
+		if qualWeighted || int(b.Qual) >= minBQ {
 			b1, found := m[b.ReadId]
 			if found {
 				if b.Base != b1.Base {
@@ -15,6 +15,7 @@ type CorrResults struct {
 	Results []CorrResult
 	ReadNum int
 	GeneLen int
+	Seed    int64 // random seed of subsampling.
 }
 
 // Collector collect correlation results.
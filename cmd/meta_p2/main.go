@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"runtime"
@@ -15,6 +17,8 @@ import (
 
 	"github.com/biogo/hts/sam"
 	"github.com/mingzhi/biogo/seq"
+	"github.com/mingzhi/meta/cov"
+	"github.com/mingzhi/meta/sim"
 	"github.com/mingzhi/ncbiftp/taxonomy"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -52,6 +56,8 @@ func main() {
 	var corrResFile string  // corr result file.
 	var geneFile string     // gene file.
 	var maxDepth float64    // max depth
+	var errorCorrect bool   // error correction
+	var seed int64          // random seed
 
 	// Parse command arguments.
 	app := kingpin.New("meta_p2", "Calculate mutation correlation from bacterial metagenomic sequence data")
@@ -71,6 +77,8 @@ func main() {
 	minAlleleDepthFlag := app.Flag("min-allele-depth", "min allele depth").Default("0").Int()
 	maxDepthFlag := app.Flag("max-depth", "max coverage depth for each gene").Default("0").Float64()
 	minReadLenFlag := app.Flag("min-read-length", "minimal read length").Default("60").Int()
+	errorCorrectFlag := app.Flag("error-correction", "report sequencing-error corrected results").Default("false").Bool()
+	seedFlag := app.Flag("seed", "random seed of subsampling").Default("1").Int64()
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	bamFile = *bamFileArg
@@ -92,6 +100,8 @@ func main() {
 	MinAlleleDepth = *minAlleleDepthFlag
 	maxDepth = *maxDepthFlag
 	MinReadLength = *minReadLenFlag
+	errorCorrect = *errorCorrectFlag
+	seed = *seedFlag
 
 	runtime.GOMAXPROCS(ncpu)
 
@@ -119,7 +129,10 @@ func main() {
 
 	done := make(chan bool)
 	p2Chan := make(chan CorrResults)
+	errCalcs := make([]*cov.MateErrorCalculator, ncpu)
 	for i := 0; i < ncpu; i++ {
+		errCalc := cov.NewMateErrorCalculator()
+		errCalcs[i] = errCalc
 		go func() {
 			for geneRecords := range recordsChan {
 				if geneFile != "" {
@@ -128,7 +141,10 @@ func main() {
 					}
 				}
 				if maxDepth > 0 {
-					geneRecords = subsample(geneRecords, maxDepth)
+					geneRecords = subsample(geneRecords, maxDepth, sim.NewJobRand(seed, geneRecords.ID))
+				}
+				if errorCorrect {
+					errCalc.Append(mateError(geneRecords.Records))
 				}
 				geneLen := geneRecords.End - geneRecords.Start
 				gene := pileupCodons(geneRecords)
@@ -137,7 +153,7 @@ func main() {
 					p2 := calcP2(gene, maxl, minDepth, codeTable)
 					p4 := calcP4(gene, maxl, minDepth, codeTable)
 					p2 = append(p2, p4...)
-					p2Chan <- CorrResults{Results: p2, GeneID: geneRecords.ID, GeneLen: geneLen, ReadNum: len(geneRecords.Records)}
+					p2Chan <- CorrResults{Results: p2, GeneID: geneRecords.ID, GeneLen: geneLen, ReadNum: len(geneRecords.Records), Seed: seed}
 				}
 			}
 			done <- true
@@ -179,7 +195,19 @@ func main() {
 	defer w.Close()
 
 	w.WriteString("l,m,v,n,t,b\n")
+	writeSeed(outFile, seed)
 	results := collector.Results()
+	if errorCorrect {
+		errCalc := cov.NewMateErrorCalculator()
+		for _, c := range errCalcs {
+			errCalc.Append(c)
+		}
+		errRate := errCalc.Rate()
+		log.Printf("Sequencing error rate: %g, from %d sites\n", errRate, errCalc.Sites)
+		if !math.IsNaN(errRate) {
+			results = append(results, correctResults(results, cov.NewErrorModel(errRate, errRate))...)
+		}
+	}
 	for _, res := range results {
 		w.WriteString(fmt.Sprintf("%d,%g,%g,%d,%s,all\n",
 			res.Lag, res.Value, res.Variance, res.Count, res.Type))
@@ -307,10 +335,14 @@ func calcP2(gene *CodonGene, maxl, minDepth int, codeTable *taxonomy.GeneticCode
 	return
 }
 
+// calcP4 returns the mean product of diversities of two sites by lag (P4),
+// and the mean diversity of the two sites (P4_mean).
 func calcP4(gene *CodonGene, maxl, minDepth int, codeTable *taxonomy.GeneticCode) (p4Res []CorrResult) {
 	var valueArray []float64
 	var countArray []int
 	var posArray []int
+	// mean diversity of the two sites of P4, for error correction.
+	var meanRes []CorrResult
 	for i := 0; i < gene.Len(); i++ {
 		value, count := autoCov(gene, i, minDepth, codeTable)
 		if count > 0 {
@@ -337,13 +369,16 @@ func calcP4(gene *CodonGene, maxl, minDepth int, codeTable *taxonomy.GeneticCode
 			}
 			for len(p4Res) <= lag {
 				p4Res = append(p4Res, CorrResult{Type: "P4", Lag: len(p4Res)})
+				meanRes = append(meanRes, CorrResult{Type: "P4_mean", Lag: len(meanRes)})
 			}
 			p4Res[lag].Value += xbar * ybar
 			p4Res[lag].Count++
+			meanRes[lag].Value += (xbar + ybar) / 2
+			meanRes[lag].Count++
 		}
 	}
 
-	return
+	return append(p4Res, meanRes...)
 }
 
 func autoCov(gene *CodonGene, i, minDepth int, codeTable *taxonomy.GeneticCode) (value float64, count int) {
@@ -449,8 +484,9 @@ func readLines(filename string) []string {
 	return lines
 }
 
-// subsample
-func subsample(geneRecords GeneSamRecords, maxDepth float64) GeneSamRecords {
+// subsample reads of a gene to the max depth,
+// using the random generator of the gene.
+func subsample(geneRecords GeneSamRecords, maxDepth float64, rng *rand.Rand) GeneSamRecords {
 	length := float64(geneRecords.End - geneRecords.Start)
 	readNum := len(geneRecords.Records)
 	readLen := float64(geneRecords.Records[0].Len())
@@ -463,10 +499,18 @@ func subsample(geneRecords GeneSamRecords, maxDepth float64) GeneSamRecords {
 	geneRecords.Records = []*sam.Record{}
 	ratio := float64(maxReadNum) / float64(readNum)
 	for _, read := range oldRecords {
-		if rand.Float64() < ratio {
+		if rng.Float64() < ratio {
 			geneRecords.Records = append(geneRecords.Records, read)
 		}
 	}
 
 	return geneRecords
 }
+
+// writeSeed writes the random seed of subsampling to a sidecar file
+// of the output, which is kept a plain csv file.
+func writeSeed(outFile string, seed int64) {
+	if err := ioutil.WriteFile(outFile+".seed", []byte(fmt.Sprintf("%d\n", seed)), 0644); err != nil {
+		log.Panic(err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/biogo/hts/sam"
+	"github.com/mingzhi/meta/cov"
+)
+
+// mateError compares overlapping parts of paired-end mates in a gene,
+// for estimating the sequencing error rate.
+func mateError(records []*sam.Record) *cov.MateErrorCalculator {
+	m := cov.NewMateErrorCalculator()
+	mates := make(map[string]*sam.Record)
+	for _, r := range records {
+		mate, found := mates[r.Name]
+		if !found {
+			mates[r.Name] = r
+			continue
+		}
+		delete(mates, r.Name)
+
+		left, right := mate, r
+		if left.Pos > right.Pos {
+			left, right = right, left
+		}
+		s1, _ := Map2Ref(left)
+		s2, _ := Map2Ref(right)
+		start := right.Pos
+		end := left.Pos + len(s1)
+		if right.Pos+len(s2) < end {
+			end = right.Pos + len(s2)
+		}
+		if end > start {
+			m.Increment(s1[start-left.Pos:end-left.Pos], s2[:end-start])
+		}
+	}
+	return m
+}
+
+// correctResults returns error-corrected copies of Ks, P2 and P4 results,
+// whose types are appended by "_corrected".
+// P2 is corrected as the joint probability of differences in two reads,
+// and P4 as the product of diversities of two sites,
+// each of which is corrected separately using P4_mean at the same lag.
+func correctResults(results []CorrResult, model *cov.ErrorModel) (corrected []CorrResult) {
+	ks := 0.0
+	p4Means := make(map[int]float64)
+	for _, res := range results {
+		switch res.Type {
+		case "Ks":
+			ks = res.Value
+		case "P4_mean":
+			p4Means[res.Lag] = res.Value
+		}
+	}
+	if ks == 0 {
+		return
+	}
+
+	ksCorrected := model.CorrectKs(ks)
+	c := 1 - model.A - model.B
+	for _, res := range results {
+		res1 := res
+		res1.Type = res.Type + "_corrected"
+		// results other than Ks are normalized by Ks.
+		raw := res.Value * ks
+		switch res.Type {
+		case "Ks":
+			res1.Value = ksCorrected
+			scale := ksCorrected / ks
+			res1.Variance = res.Variance * scale * scale
+		case "P4":
+			mean, found := p4Means[res.Lag]
+			if !found {
+				continue
+			}
+			res1.Value = model.CorrectProduct(raw, mean*ks) / ksCorrected
+			scale := ks / (ksCorrected * c * c)
+			res1.Variance = res.Variance * scale * scale
+		case "P4_mean":
+			res1.Value = model.CorrectKs(raw) / ksCorrected
+			scale := ks / (ksCorrected * c)
+			res1.Variance = res.Variance * scale * scale
+		default:
+			res1.Value = model.CorrectJoint(raw, ks) / ksCorrected
+			scale := ks / (ksCorrected * c * c)
+			res1.Variance = res.Variance * scale * scale
+		}
+		corrected = append(corrected, res1)
+	}
+	return
+}
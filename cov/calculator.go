@@ -7,7 +7,8 @@ import (
 )
 
 type CovCalculator struct {
-	corrs []*correlation.BivariateCovariance
+	corrs  []*correlation.BivariateCovariance
+	wcorrs []*WeightedCovariance
 }
 
 func NewCovCalculator(maxl int, bias bool) *CovCalculator {
@@ -19,28 +20,135 @@ func NewCovCalculator(maxl int, bias bool) *CovCalculator {
 	return &cc
 }
 
+// NewWeightedCovCalculator returns a CovCalculator,
+// which accumulates weighted moments.
+func NewWeightedCovCalculator(maxl int, bias bool) *CovCalculator {
+	cc := CovCalculator{}
+	cc.wcorrs = make([]*WeightedCovariance, maxl)
+	for i := 0; i < maxl; i++ {
+		cc.wcorrs[i] = NewWeightedCovariance(bias)
+	}
+	return &cc
+}
+
 func (cc *CovCalculator) Increment(i int, x, y float64) {
-	cc.corrs[i].Increment(x, y)
+	cc.IncrementWeighted(i, x, y, 1.0)
+}
+
+// IncrementWeighted adds a pair (x, y) with weight w.
+// The weight is ignored if the calculator is not weighted.
+func (cc *CovCalculator) IncrementWeighted(i int, x, y, w float64) {
+	if cc.wcorrs != nil {
+		cc.wcorrs[i].Increment(x, y, w)
+	} else {
+		cc.corrs[i].Increment(x, y)
+	}
 }
 
 func (cc *CovCalculator) GetResult(i int) float64 {
+	if cc.wcorrs != nil {
+		return cc.wcorrs[i].GetResult()
+	}
 	return cc.corrs[i].GetResult()
 }
 
 func (cc *CovCalculator) GetMeanXY(i int) float64 {
+	if cc.wcorrs != nil {
+		return cc.wcorrs[i].MeanX() * cc.wcorrs[i].MeanY()
+	}
 	return cc.corrs[i].MeanX() * cc.corrs[i].MeanY()
 }
 
 func (cc *CovCalculator) GetN(i int) int {
+	if cc.wcorrs != nil {
+		return cc.wcorrs[i].GetN()
+	}
 	return cc.corrs[i].GetN()
 }
 
 func (cc *CovCalculator) Append(cc2 *CovCalculator) {
+	if cc.wcorrs != nil {
+		for i := 0; i < len(cc.wcorrs); i++ {
+			cc.wcorrs[i].Append(cc2.wcorrs[i])
+		}
+		return
+	}
 	for i := 0; i < len(cc.corrs); i++ {
 		cc.corrs[i].Append(cc2.corrs[i])
 	}
 }
 
+// WeightedCovariance calculates weighted covariance in the increment way.
+type WeightedCovariance struct {
+	N              int     // number of values.
+	W, W2          float64 // sum of weights, and of squared weights.
+	MX, MY         float64 // weighted means.
+	CXY            float64 // weighted sum of co-deviations.
+	BiasCorrection bool
+}
+
+// NewWeightedCovariance returns a new WeightedCovariance.
+func NewWeightedCovariance(biasCorrection bool) *WeightedCovariance {
+	return &WeightedCovariance{BiasCorrection: biasCorrection}
+}
+
+// Increment adds a pair (x, y) with weight w.
+func (c *WeightedCovariance) Increment(x, y, w float64) {
+	if w <= 0 {
+		return
+	}
+	c.N++
+	c.W += w
+	c.W2 += w * w
+	dx := x - c.MX
+	c.MX += dx * w / c.W
+	c.MY += (y - c.MY) * w / c.W
+	c.CXY += w * dx * (y - c.MY)
+}
+
+// GetResult returns the weighted covariance.
+// With bias correction, it uses reliability weights.
+func (c *WeightedCovariance) GetResult() float64 {
+	if c.N < 2 {
+		return math.NaN()
+	}
+	if c.BiasCorrection {
+		return c.CXY / (c.W - c.W2/c.W)
+	}
+	return c.CXY / c.W
+}
+
+// MeanX returns the weighted mean of x.
+func (c *WeightedCovariance) MeanX() float64 {
+	return c.MX
+}
+
+// MeanY returns the weighted mean of y.
+func (c *WeightedCovariance) MeanY() float64 {
+	return c.MY
+}
+
+// GetN returns the number of pairs.
+func (c *WeightedCovariance) GetN() int {
+	return c.N
+}
+
+// Append merges another WeightedCovariance.
+func (c *WeightedCovariance) Append(c2 *WeightedCovariance) {
+	if c2.N == 0 {
+		return
+	}
+	w := c.W + c2.W
+	dx := c2.MX - c.MX
+	dy := c2.MY - c.MY
+	c.CXY += c2.CXY + dx*dy*c.W*c2.W/w
+	c.MX += dx * c2.W / w
+	c.MY += dy * c2.W / w
+	c.W = w
+	c.W2 += c2.W2
+	c.N += c2.N
+}
+
 type MeanVar struct {
 	Mean           *desc.Mean
 	Var            *desc.Variance
@@ -0,0 +1,87 @@
+package cov
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedCovarianceEqualWeights(t *testing.T) {
+	xs := []float64{0, 1, 1, 0, 1, 0, 0, 1}
+	ys := []float64{0, 1, 0, 0, 1, 1, 0, 1}
+	for _, bias := range []bool{true, false} {
+		c := NewWeightedCovariance(bias)
+		for i := range xs {
+			c.Increment(xs[i], ys[i], 0.5)
+		}
+		expected := covariance(xs, ys, bias)
+		if math.Abs(c.GetResult()-expected) > 1e-12 {
+			t.Errorf("bias %v: expect %g, got %g\n", bias, expected, c.GetResult())
+		}
+		if c.GetN() != len(xs) {
+			t.Errorf("expect %d pairs, got %d\n", len(xs), c.GetN())
+		}
+	}
+}
+
+func TestWeightedCovarianceIntegerWeights(t *testing.T) {
+	// an integer weight is the same as repeating the pair without bias correction.
+	xs := []float64{0, 1, 1, 0, 1}
+	ys := []float64{0, 1, 0, 0, 1}
+	ws := []float64{1, 3, 2, 1, 2}
+	c := NewWeightedCovariance(false)
+	rx, ry := []float64{}, []float64{}
+	for i := range xs {
+		c.Increment(xs[i], ys[i], ws[i])
+		for j := 0; j < int(ws[i]); j++ {
+			rx = append(rx, xs[i])
+			ry = append(ry, ys[i])
+		}
+	}
+	expected := covariance(rx, ry, false)
+	if math.Abs(c.GetResult()-expected) > 1e-12 {
+		t.Errorf("expect %g, got %g\n", expected, c.GetResult())
+	}
+}
+
+func TestWeightedCovarianceAppend(t *testing.T) {
+	xs := []float64{0, 1, 1, 0, 1, 0, 0, 1}
+	ys := []float64{0, 1, 0, 0, 1, 1, 0, 1}
+	ws := []float64{0.9, 0.5, 1, 0.2, 0.8, 0.7, 1, 0.3}
+	all := NewWeightedCovariance(true)
+	c1 := NewWeightedCovariance(true)
+	c2 := NewWeightedCovariance(true)
+	for i := range xs {
+		all.Increment(xs[i], ys[i], ws[i])
+		if i < 3 {
+			c1.Increment(xs[i], ys[i], ws[i])
+		} else {
+			c2.Increment(xs[i], ys[i], ws[i])
+		}
+	}
+	c1.Append(c2)
+	values := [][]float64{
+		{all.GetResult(), c1.GetResult()},
+		{all.MeanX(), c1.MeanX()},
+		{all.MeanY(), c1.MeanY()},
+	}
+	for i, v := range values {
+		if math.Abs(v[0]-v[1]) > 1e-12 {
+			t.Errorf("%d, expect %g, got %g\n", i, v[0], v[1])
+		}
+	}
+	if c1.GetN() != all.GetN() {
+		t.Errorf("expect %d pairs, got %d\n", all.GetN(), c1.GetN())
+	}
+}
+
+func TestWeightedCovarianceSkipsZeroWeights(t *testing.T) {
+	c := NewWeightedCovariance(true)
+	c.Increment(1, 1, 0)
+	c.Increment(0, 1, 1)
+	if c.GetN() != 1 {
+		t.Errorf("expect 1 pair, got %d\n", c.GetN())
+	}
+	if !math.IsNaN(c.GetResult()) {
+		t.Errorf("expect NaN with one pair, got %g\n", c.GetResult())
+	}
+}
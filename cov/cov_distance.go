@@ -0,0 +1,179 @@
+package cov
+
+import (
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/reads"
+	"runtime"
+)
+
+// Calculators of correlations by physical genome distance,
+// in which site pairs are separated by their genes and strands.
+type DistCalculators struct {
+	Ks             *KsCalculator
+	SameGene       *CovCalculator // both sites in the same gene.
+	DifferentGene  *CovCalculator // sites in different genes, on either strand.
+	SameStrand     *CovCalculator // sites in different genes on the same strand.
+	OppositeStrand *CovCalculator // sites in different genes on opposite strands.
+}
+
+func NewDistCalculators(maxl int, biasCorrection bool) *DistCalculators {
+	c := DistCalculators{}
+	c.Ks = NewKsCalculator()
+	c.SameGene = NewCovCalculator(maxl, biasCorrection)
+	c.DifferentGene = NewCovCalculator(maxl, biasCorrection)
+	c.SameStrand = NewCovCalculator(maxl, biasCorrection)
+	c.OppositeStrand = NewCovCalculator(maxl, biasCorrection)
+	return &c
+}
+
+func (c *DistCalculators) Append(c2 *DistCalculators) {
+	c.Ks.Append(c2.Ks)
+	c.SameGene.Append(c2.SameGene)
+	c.DifferentGene.Append(c2.DifferentGene)
+	c.SameStrand.Append(c2.SameStrand)
+	c.OppositeStrand.Append(c2.OppositeStrand)
+}
+
+// Calculate correlation of substitutions by genome distance,
+// spanning gene boundaries, for sites in a substitution profile
+// starting at a genome position.
+// geneIndex: gene index of each genome position (see genome.GeneIndex).
+func SubCorrByDist(subs []float64, start int, geneIndex []int, genes []genome.Gene, c *DistCalculators, maxl int) {
+	ints := getPosIndices(subs)
+	for _, i := range ints {
+		c.Ks.Increment(subs[i])
+		if geneIndex[start+i] >= 0 {
+			c.SameGene.Increment(0, subs[i], subs[i])
+		}
+	}
+
+	for j := 0; j < len(ints); j++ {
+		g1 := geneIndex[start+ints[j]]
+		if g1 < 0 {
+			continue
+		}
+		for k := j + 1; k < len(ints); k++ {
+			l := ints[k] - ints[j]
+			if l >= maxl {
+				break
+			}
+			g2 := geneIndex[start+ints[k]]
+			if g2 < 0 {
+				continue
+			}
+
+			x, y := subs[ints[j]], subs[ints[k]]
+			if g1 == g2 {
+				c.SameGene.Increment(l, x, y)
+				continue
+			}
+			c.DifferentGene.Increment(l, x, y)
+			if genes[g1].Strand == genes[g2].Strand {
+				c.SameStrand.Increment(l, x, y)
+			} else {
+				c.OppositeStrand.Increment(l, x, y)
+			}
+		}
+	}
+}
+
+// Calculate correlation of substitutions in reads by genome distance,
+// by comparing them to the reference genome,
+// whose genes have to be loaded.
+func ReadsVsGenomeByDist(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int) *DistCalculators {
+	pairs := make(chan matePair)
+	go func() {
+		defer close(pairs)
+		for _, r := range matedReads {
+			sameRef := r.ReadLeft.Ref.Name() == r.ReadRight.Ref.Name()
+			matchRef := genome.FindRefAcc(r.ReadLeft.Ref.Name()) == g.RefAcc()
+			if sameRef && matchRef {
+				pairs <- matePair{r1: r}
+			}
+		}
+	}()
+
+	profile := func(pair matePair) (start int, subs []float64) {
+		read := reads.MapMated2Ref(pair.r1)
+		start = pair.r1.ReadLeft.Pos
+		end := start + len(read)
+		if end <= len(g.Seq) && end <= len(g.PosProfile) {
+			subs = SubProfile(read, g.Seq[start:end], g.PosProfile[start:end], pos)
+		}
+		return
+	}
+
+	return distCalc(pairs, profile, g, maxl)
+}
+
+// Calculate correlation of substitutions in reads by genome distance,
+// by comparing overlapping reads to reads.
+// matedReads should be sorted by right coordinate.
+func ReadsVsReadsByDist(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int) *DistCalculators {
+	pairs := make(chan matePair)
+	go func() {
+		defer close(pairs)
+		for i := 0; i < len(matedReads); i++ {
+			r1 := matedReads[i]
+			for j := i - 1; j >= 0; j-- {
+				r2 := matedReads[j]
+				if r2.ReadRight.Pos+r2.ReadRight.Len() < r1.ReadLeft.Pos {
+					break
+				}
+				pairs <- matePair{r1, r2}
+			}
+		}
+	}()
+
+	profile := func(pair matePair) (start int, subs []float64) {
+		r1, r2 := pair.r1, pair.r2
+		read1 := reads.MapMated2Ref(r1)
+		read2 := reads.MapMated2Ref(r2)
+		start = maxInt(r1.ReadLeft.Pos, r2.ReadLeft.Pos)
+		end := minInt(r1.ReadLeft.Pos+len(read1), r2.ReadLeft.Pos+len(read2))
+		if end > start && end <= len(g.PosProfile) {
+			nucl1 := read1[start-r1.ReadLeft.Pos : end-r1.ReadLeft.Pos]
+			nucl2 := read2[start-r2.ReadLeft.Pos : end-r2.ReadLeft.Pos]
+			subs = SubProfile(nucl1, nucl2, g.PosProfile[start:end], pos)
+		}
+		return
+	}
+
+	return distCalc(pairs, profile, g, maxl)
+}
+
+// distProfile maps a job of reads to the reference genome,
+// and returns the substitution profile starting at a genome position,
+// or nil if the reads are out of the genome.
+type distProfile func(pair matePair) (start int, subs []float64)
+
+// Run ncpu workers, each of which profiles and correlates jobs of reads.
+func distCalc(pairs chan matePair, profile distProfile, g genome.Genome, maxl int) (c *DistCalculators) {
+	geneIndex := g.GeneIndex()
+	ncpu := runtime.GOMAXPROCS(0)
+	results := make(chan *DistCalculators)
+	for i := 0; i < ncpu; i++ {
+		go func() {
+			biasCorrection := true
+			dc := NewDistCalculators(maxl, biasCorrection)
+			for pair := range pairs {
+				start, subs := profile(pair)
+				if subs != nil {
+					SubCorrByDist(subs, start, geneIndex, g.Genes, dc, maxl)
+				}
+			}
+			results <- dc
+		}()
+	}
+
+	for i := 0; i < ncpu; i++ {
+		res := <-results
+		if i == 0 {
+			c = res
+		} else {
+			c.Append(res)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,77 @@
+package cov
+
+import (
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/reads"
+	"math"
+	"sort"
+	"testing"
+)
+
+// a genome of 30 four-fold sites in two genes on the plus strand,
+// followed by one on the minus strand.
+func distGenome() genome.Genome {
+	g, _ := crossGenomes(32)
+	g.Seq = g.Seq[:30]
+	g.PosProfile = g.PosProfile[:30]
+	g.Genes = []genome.Gene{{From: 1, To: 10, Strand: "+"}, {From: 11, To: 20, Strand: "+"}, {From: 21, To: 30, Strand: "-"}}
+	return g
+}
+
+func TestSubCorrByDist(t *testing.T) {
+	g := distGenome()
+	subs := make([]float64, len(g.PosProfile))
+	for i := range subs {
+		if i%4 == 0 {
+			subs[i] = 1
+		}
+	}
+
+	maxl := 12
+	c := NewDistCalculators(maxl, true)
+	SubCorrByDist(subs, 0, g.GeneIndex(), g.Genes, c, maxl)
+
+	values := []struct {
+		name     string
+		cc       *CovCalculator
+		lag, num int
+	}{
+		{"same gene", c.SameGene, 1, 27},
+		{"different gene", c.DifferentGene, 1, 2},
+		{"same strand", c.SameStrand, 1, 1},
+		{"opposite strand", c.OppositeStrand, 1, 1},
+		{"same gene", c.SameGene, 10, 0},
+		{"different gene", c.DifferentGene, 10, 20},
+		{"same strand", c.SameStrand, 10, 10},
+		{"opposite strand", c.OppositeStrand, 10, 10},
+	}
+	for _, v := range values {
+		if n := v.cc.GetN(v.lag); n != v.num {
+			t.Errorf("%s, lag %d: expect %d pairs, got %d\n", v.name, v.lag, v.num, n)
+		}
+	}
+}
+
+func TestReadsVsReadsByDist(t *testing.T) {
+	g := distGenome()
+	_, variant := crossGenomes(32)
+	matedReads := append(tileReads(g.Seq, 5, 1), tileReads(variant[:30], 5, 1)...)
+	sort.Sort(reads.ByRightCoordinatePairedEndReads{PairedEndReads: matedReads})
+
+	maxl := 12
+	c := ReadsVsReadsByDist(matedReads, g, maxl, 4)
+	// differences at sites 0, 4, 8, 12, 16, 20, 24 and 28.
+	if ks := c.Ks.Mean.GetResult(); math.Abs(ks-8.0/30.0) > 1e-12 {
+		t.Errorf("expect Ks %g, got %g\n", 8.0/30.0, ks)
+	}
+	for l := 1; l < maxl; l++ {
+		n := c.DifferentGene.GetN(l)
+		if n != c.SameStrand.GetN(l)+c.OppositeStrand.GetN(l) {
+			t.Errorf("lag %d: expect %d different-gene pairs, got %d\n", l,
+				c.SameStrand.GetN(l)+c.OppositeStrand.GetN(l), n)
+		}
+	}
+	if n := c.SameGene.GetN(0); n != 30 {
+		t.Errorf("expect 30 same-gene sites, got %d\n", n)
+	}
+}
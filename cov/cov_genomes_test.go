@@ -1,9 +1,51 @@
 package cov
 
 import (
+	"github.com/mingzhi/meta/fit"
+	"github.com/mingzhi/meta/sim"
+	"math"
+	"math/rand"
 	"testing"
 )
 
-func TestGetProfile(t *testing.T) {
+func TestGenomesCalcRecomb(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip simulations in short mode")
+	}
 
+	// independent pairs of genomes, whose mutation rates are uniform,
+	// so that Ct is the linkage covariance Cs.
+	p := sim.Params{SampleSize: 2, Length: 20000, Theta: 0.1, Rho: 0.02, TractLength: 100}
+	lay := sim.Layout{GeneLength: 900, Spacer: 100}
+	maxl, pos := 300, 3
+	rng := rand.New(rand.NewSource(1))
+
+	c := NewCalculators(maxl, false)
+	for rep := 0; rep < 100; rep++ {
+		genomes := sim.Genomes(sim.Simulate(p, rng), lay.Genes(p.Length, rng))
+		for _, c2 := range GenomesCalc(sim.Alignments(genomes), genomes[0], maxl, pos, GenomesVsGenomesOne) {
+			c.Append(c2)
+		}
+	}
+
+	ks := c.Ks.Mean.GetResult()
+	l, ct := []float64{}, []float64{}
+	for i := 0; i < maxl; i += 3 {
+		l = append(l, float64(i))
+		ct = append(ct, c.TCov.GetResult(i))
+	}
+	res := fit.FitRecomb(ks, l, ct)
+
+	expected := p.Theta / (1 + 4*p.Theta/3)
+	values := [][]float64{{expected, ks}, {p.Theta, res.Theta}, {p.TractLength, res.Fragment}}
+	for i, v := range values {
+		if math.IsNaN(v[1]) || math.Abs(v[1]-v[0]) > 0.4*v[0] {
+			t.Errorf("%d, Expect %f, got %f\n", i, v[0], v[1])
+		}
+	}
+	// phi is the rate of events changing the coalescence time of a site,
+	// less than rho, since converted fragments may coalesce back with their own lineage.
+	if math.IsNaN(res.Phi) || res.Phi < p.Rho/4 || res.Phi > p.Rho {
+		t.Errorf("Expect phi in [%f, %f], got %f\n", p.Rho/4, p.Rho, res.Phi)
+	}
 }
@@ -14,6 +14,18 @@ import (
 // maxl: max length of correlations;
 // pos: positions to be calculated.
 func ReadsVsGenome(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int) (kc *KsCalculator, cc *CovCalculator) {
+	return pairedReadsVsGenome(matedReads, g, maxl, pos, false, 0)
+}
+
+// Calculate quality-weighted correlation of substituions in reads,
+// by comparing them to the reference genome.
+// Each site is the posterior probability of a true difference,
+// given the base quality of the read and the prior probability of a true difference.
+func ReadsVsGenomeQual(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int, prior float64) (kc *KsCalculator, cc *CovCalculator) {
+	return pairedReadsVsGenome(matedReads, g, maxl, pos, true, prior)
+}
+
+func pairedReadsVsGenome(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int, qual bool, prior float64) (kc *KsCalculator, cc *CovCalculator) {
 	// Prepare jobs.
 	type job struct {
 		r reads.PairedEndRead
@@ -39,20 +51,25 @@ func ReadsVsGenome(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos i
 	ncpu := runtime.GOMAXPROCS(0)
 	for i := 0; i < ncpu; i++ {
 		go func() {
-			cc := NewCovCalculator(maxl, true)
+			cc := newCovCalculator(maxl, true, qual)
 			kc := NewKsCalculator()
 			for j := range jobs {
 				rec := j.r
 				// mapped paired end read to the reference genome.
-				read := reads.MapMated2Ref(rec)
+				read, readQual := mapMated(rec, qual)
 
 				if rec.ReadLeft.Pos+len(read) <= len(g.Seq) {
 					start := rec.ReadLeft.Pos
 					end := rec.ReadLeft.Pos + len(read)
 					nucl := g.Seq[start:end]
 					profile := g.PosProfile[start:end]
-					subs := SubProfile(read, nucl, profile, pos)
-					SubCorr(subs, cc, kc, maxl)
+					if qual {
+						subs, weights := SubProfileQual(read, nucl, readQual, nil, profile, pos, prior)
+						SubCorrWeighted(subs, weights, cc, kc, maxl)
+					} else {
+						subs := SubProfile(read, nucl, profile, pos)
+						SubCorr(subs, cc, kc, maxl)
+					}
 				} else {
 					log.Printf("%d, %d, %d\n", rec.ReadLeft.Pos-1, rec.ReadLeft.Pos+len(read), len(g.PosProfile))
 				}
@@ -83,14 +100,22 @@ func ReadsVsGenome(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos i
 // maxl: max length of correlations;
 // pos: postions to be calculated.
 func ReadsVsReads(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int) (kc *KsCalculator, cc *CovCalculator) {
-	ncpu := runtime.GOMAXPROCS(0)
+	return pairedReadsVsReads(matedReads, g, maxl, pos, false, 0)
+}
+
+// Calculate quality-weighted correlation of substituions in reads,
+// by comparing reads to reads.
+// Each site is the posterior probability of a true difference,
+// given the base qualities of both reads and the prior probability of a true difference.
+func ReadsVsReadsQual(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int, prior float64) (kc *KsCalculator, cc *CovCalculator) {
+	return pairedReadsVsReads(matedReads, g, maxl, pos, true, prior)
+}
+
+func pairedReadsVsReads(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos int, qual bool, prior float64) (kc *KsCalculator, cc *CovCalculator) {
 	// Create job channel.
-	type job struct {
-		r1, r2 reads.PairedEndRead
-	}
-	jobs := make(chan job)
+	pairs := make(chan matePair)
 	go func() {
-
+		defer close(pairs)
 		for i := 0; i < len(matedReads); i++ {
 			r1 := matedReads[i]
 			for j := i - 1; j >= 0; j-- {
@@ -98,46 +123,65 @@ func ReadsVsReads(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos in
 				if r2.ReadRight.Pos+r2.ReadRight.Len() < r1.ReadLeft.Pos {
 					break
 				} else {
-					jobs <- job{r1, r2}
+					pairs <- matePair{r1, r2}
 				}
 			}
 		}
-		close(jobs)
 	}()
 
+	return readPairsCov(pairs, g, maxl, pos, qual, prior)
+}
+
+// A pair of mated reads to be compared in their overlapping region.
+type matePair struct {
+	r1, r2 reads.PairedEndRead
+}
+
+// Calculate correlation of substitutions between pairs of mated reads,
+// which are compared in their overlapping regions by ncpu workers,
+// and quality-weighted with the prior probability of a true difference if qual is set.
+func readPairsCov(pairs <-chan matePair, g genome.Genome, maxl, pos int, qual bool, prior float64) (kc *KsCalculator, cc *CovCalculator) {
 	type result struct {
 		cc *CovCalculator
 		kc *KsCalculator
 	}
 	results := make(chan result)
 
+	ncpu := runtime.GOMAXPROCS(0)
 	for i := 0; i < ncpu; i++ {
 		go func() {
 			// prepare calculators.
 			biasCorrection := true
-			cc := NewCovCalculator(maxl, biasCorrection)
+			cc := newCovCalculator(maxl, biasCorrection, qual)
 			kc := NewKsCalculator()
 
-			// do calculation for each job.
-			for job := range jobs {
-				r1, r2 := job.r1, job.r2
-				read1 := reads.MapMated2Ref(r1)
-				read2 := reads.MapMated2Ref(r2)
-
-				// double check if overlap.
-				if r2.ReadLeft.Pos+len(read2) > r1.ReadLeft.Pos {
-					// Determine overlap regions (in genome coordinate).
-					start := maxInt(r1.ReadLeft.Pos, r2.ReadLeft.Pos)
-					end := minInt(r1.ReadLeft.Pos+len(read1), r2.ReadLeft.Pos+len(read2))
-
-					// Prepare profile and read sequences.
-					profile := g.PosProfile[start:end]
-					nucl1 := read1[start-r1.ReadLeft.Pos : end-r1.ReadLeft.Pos]
-					nucl2 := read2[start-r2.ReadLeft.Pos : end-r2.ReadLeft.Pos]
+			// do calculation for each pair.
+			for pair := range pairs {
+				r1, r2 := pair.r1, pair.r2
+				read1, qual1 := mapMated(r1, qual)
+				read2, qual2 := mapMated(r2, qual)
+
+				// Determine overlap regions (in genome coordinate).
+				start := maxInt(r1.ReadLeft.Pos, r2.ReadLeft.Pos)
+				end := minInt(r1.ReadLeft.Pos+len(read1), r2.ReadLeft.Pos+len(read2))
+				end = minInt(end, len(g.PosProfile))
+				if end <= start {
+					continue
+				}
 
-					// Subsitution profiling.
+				// Prepare profile and read sequences.
+				profile := g.PosProfile[start:end]
+				nucl1 := read1[start-r1.ReadLeft.Pos : end-r1.ReadLeft.Pos]
+				nucl2 := read2[start-r2.ReadLeft.Pos : end-r2.ReadLeft.Pos]
+
+				// Subsitution profiling.
+				if qual {
+					q1 := qual1[start-r1.ReadLeft.Pos : end-r1.ReadLeft.Pos]
+					q2 := qual2[start-r2.ReadLeft.Pos : end-r2.ReadLeft.Pos]
+					subs, weights := SubProfileQual(nucl1, nucl2, q1, q2, profile, pos, prior)
+					SubCorrWeighted(subs, weights, cc, kc, maxl)
+				} else {
 					subs := SubProfile(nucl1, nucl2, profile, pos)
-
 					SubCorr(subs, cc, kc, maxl)
 				}
 			}
@@ -160,6 +204,23 @@ func ReadsVsReads(matedReads reads.PairedEndReads, g genome.Genome, maxl, pos in
 	return
 }
 
+// map a pair of mated reads to the reference genome,
+// with base qualities only if they are used.
+func mapMated(r reads.PairedEndRead, qual bool) (read, readQual []byte) {
+	if qual {
+		return reads.MapMated2RefQual(r)
+	}
+	return reads.MapMated2Ref(r), nil
+}
+
+// return a weighted or an unweighted CovCalculator.
+func newCovCalculator(maxl int, biasCorrection, weighted bool) *CovCalculator {
+	if weighted {
+		return NewWeightedCovCalculator(maxl, biasCorrection)
+	}
+	return NewCovCalculator(maxl, biasCorrection)
+}
+
 // return max int
 func maxInt(a, b int) int {
 	if a > b {
@@ -0,0 +1,145 @@
+package cov
+
+import (
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/reads"
+	"math"
+	"sort"
+)
+
+// Calculate cross-sample correlation of substituions in reads,
+// by comparing reads of one sample to overlapping reads of another sample,
+// both of which are mapped to the same reference genome.
+// matedReads1, matedReads2: paired-end reads of the two samples;
+// genome: Genome;
+// maxl: max length of correlations;
+// pos: postions to be calculated.
+func ReadsVsReadsCross(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int) (kc *KsCalculator, cc *CovCalculator) {
+	return crossReadsVsReads(matedReads1, matedReads2, g, maxl, pos, false, 0)
+}
+
+// Calculate quality-weighted cross-sample correlation of substituions in reads,
+// by comparing reads of one sample to overlapping reads of another sample.
+// Each site is the posterior probability of a true difference,
+// given the base qualities of both reads and the prior probability of a true difference.
+func ReadsVsReadsCrossQual(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int, prior float64) (kc *KsCalculator, cc *CovCalculator) {
+	return crossReadsVsReads(matedReads1, matedReads2, g, maxl, pos, true, prior)
+}
+
+func crossReadsVsReads(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int, qual bool, prior float64) (kc *KsCalculator, cc *CovCalculator) {
+	// Sort reads of the second sample by left coordinate,
+	// and record the longest fragment for searching overlaps.
+	others := make(reads.PairedEndReads, len(matedReads2))
+	copy(others, matedReads2)
+	sort.Sort(reads.ByLeftCoordinatePairedEndReads{PairedEndReads: others})
+	maxSpan := 0
+	for _, r := range others {
+		span := r.ReadRight.Pos + r.ReadRight.Len() - r.ReadLeft.Pos
+		if span > maxSpan {
+			maxSpan = span
+		}
+	}
+
+	// Create job channel.
+	pairs := make(chan matePair)
+	go func() {
+		defer close(pairs)
+		for _, r1 := range matedReads1 {
+			start := r1.ReadLeft.Pos - maxSpan
+			end := r1.ReadRight.Pos + r1.ReadRight.Len()
+			i := sort.Search(len(others), func(i int) bool { return others[i].ReadLeft.Pos >= start })
+			for ; i < len(others) && others[i].ReadLeft.Pos < end; i++ {
+				r2 := others[i]
+				if r2.ReadRight.Pos+r2.ReadRight.Len() > r1.ReadLeft.Pos {
+					pairs <- matePair{r1, r2}
+				}
+			}
+		}
+	}()
+
+	return readPairsCov(pairs, g, maxl, pos, qual, prior)
+}
+
+// Calculate cross-sample correlation of allele frequencies,
+// in which reads of each sample are piled up on the reference genome,
+// and each site is the probability that two bases,
+// drawn from the two samples, are different.
+// Sites without reads in either sample are skipped.
+func AlleleFreqsCross(matedReads1, matedReads2 reads.PairedEndReads, g genome.Genome, maxl, pos int) (kc *KsCalculator, cc *CovCalculator) {
+	counts1 := countAlleles(matedReads1, len(g.PosProfile))
+	counts2 := countAlleles(matedReads2, len(g.PosProfile))
+
+	cp := codonPos(pos)
+	subs := make([]float64, len(g.PosProfile))
+	for i := 0; i < len(subs); i++ {
+		if matchPos(g.PosProfile[i], cp) {
+			subs[i] = alleleDiff(counts1[i], counts2[i])
+		} else {
+			subs[i] = math.NaN()
+		}
+	}
+
+	biasCorrection := true
+	kc = NewKsCalculator()
+	cc = NewCovCalculator(maxl, biasCorrection)
+	SubCorr(subs, cc, kc, maxl)
+
+	return
+}
+
+// Count bases of paired-end reads at each position of the reference genome,
+// in the order of A, C, G and T.
+// Overlapping mates are counted once.
+func countAlleles(matedReads reads.PairedEndReads, length int) [][4]int32 {
+	counts := make([][4]int32, length)
+	for _, r := range matedReads {
+		read := reads.MapMated2Ref(r)
+		for i, b := range read {
+			p := r.ReadLeft.Pos + i
+			if p < 0 {
+				continue
+			}
+			if p >= length {
+				break
+			}
+			if k := alleleIndex(b); k >= 0 {
+				counts[p][k]++
+			}
+		}
+	}
+	return counts
+}
+
+// Return the probability that two bases drawn from two sets of allele counts are different,
+// or NaN if either set is empty.
+func alleleDiff(c1, c2 [4]int32) float64 {
+	var n1, n2 int32
+	for k := 0; k < 4; k++ {
+		n1 += c1[k]
+		n2 += c2[k]
+	}
+	if n1 == 0 || n2 == 0 {
+		return math.NaN()
+	}
+
+	same := 0.0
+	for k := 0; k < 4; k++ {
+		same += float64(c1[k]) * float64(c2[k])
+	}
+	return 1 - same/(float64(n1)*float64(n2))
+}
+
+// Return the index of a nucleotide in ACGT, or -1 for others.
+func alleleIndex(b byte) int {
+	switch b {
+	case 'A', 'a':
+		return 0
+	case 'C', 'c':
+		return 1
+	case 'G', 'g':
+		return 2
+	case 'T', 't':
+		return 3
+	}
+	return -1
+}
@@ -0,0 +1,172 @@
+package cov
+
+import (
+	"bytes"
+	"github.com/biogo/hts/sam"
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/reads"
+	"math"
+	"testing"
+)
+
+// a genome of four-fold sites, and a variant of it
+// differing at every fourth site.
+func crossGenomes(length int) (g genome.Genome, variant []byte) {
+	g.Seq = bytes.Repeat([]byte("ACGT"), length/4)
+	g.PosProfile = bytes.Repeat([]byte{genome.FourFold}, length)
+	variant = make([]byte, length)
+	copy(variant, g.Seq)
+	for i := 0; i < length; i += 4 {
+		variant[i] = 'T'
+	}
+	return
+}
+
+// tile a sequence by adjacent mates of fragments of length 2*readLen,
+// with an optional copy of each fragment.
+func tileReads(seq []byte, readLen, copies int) (matedReads reads.PairedEndReads) {
+	for start := 0; start+2*readLen <= len(seq); start += 2 * readLen {
+		for c := 0; c < copies; c++ {
+			left := mappedRead(seq, start, readLen)
+			right := mappedRead(seq, start+readLen, readLen)
+			matedReads = append(matedReads, reads.PairedEndRead{ReadLeft: left, ReadRight: right})
+		}
+	}
+	return
+}
+
+func mappedRead(seq []byte, pos, readLen int) *sam.Record {
+	return &sam.Record{
+		Pos:   pos,
+		Seq:   sam.NewSeq(seq[pos : pos+readLen]),
+		Cigar: sam.Cigar{sam.NewCigarOp(sam.CigarMatch, readLen)},
+		Qual:  bytes.Repeat([]byte{40}, readLen),
+	}
+}
+
+// expected covariance by lag of periodic differences at every fourth site,
+// in fragments of a given length.
+func periodicCov(fragments, fragLen, l int) float64 {
+	xs, ys := []float64{}, []float64{}
+	for f := 0; f < fragments; f++ {
+		for i := 0; i+l < fragLen; i++ {
+			x, y := 0.0, 0.0
+			if i%4 == 0 {
+				x = 1
+			}
+			if (i+l)%4 == 0 {
+				y = 1
+			}
+			xs = append(xs, x)
+			ys = append(ys, y)
+		}
+	}
+	return covariance(xs, ys, true)
+}
+
+func TestReadsVsReadsCross(t *testing.T) {
+	length, readLen, maxl := 40, 4, 8
+	g, variant := crossGenomes(length)
+	sample1 := tileReads(g.Seq, readLen, 1)
+	sample2 := tileReads(variant, readLen, 1)
+
+	kc, _ := ReadsVsReadsCross(sample1, sample1, g, maxl, 4)
+	if ks := kc.Mean.GetResult(); ks != 0 {
+		t.Errorf("identical samples: expect Ks 0, got %g\n", ks)
+	}
+
+	kc, cc := ReadsVsReadsCross(sample1, sample2, g, maxl, 4)
+	if ks := kc.Mean.GetResult(); math.Abs(ks-0.25) > 1e-12 {
+		t.Errorf("expect Ks 0.25, got %g\n", ks)
+	}
+	fragments := length / (2 * readLen)
+	for l := 1; l < maxl; l++ {
+		expected := periodicCov(fragments, 2*readLen, l)
+		if math.Abs(cc.GetResult(l)-expected) > 1e-12 {
+			t.Errorf("lag %d: expect %g, got %g\n", l, expected, cc.GetResult(l))
+		}
+	}
+
+	// base qualities of 40 scale differences to posterior probabilities.
+	e := PhredErr(40)
+	p1, p0 := DiffProb(true, e, e, DefaultSubPrior), DiffProb(false, e, e, DefaultSubPrior)
+	kc, cc = ReadsVsReadsCrossQual(sample1, sample2, g, maxl, 4, DefaultSubPrior)
+	if ks, expected := kc.Mean.GetResult(), 0.25*p1+0.75*p0; math.Abs(ks-expected) > 1e-12 {
+		t.Errorf("quality-weighted: expect Ks %g, got %g\n", expected, ks)
+	}
+	for l := 1; l < maxl; l++ {
+		expected := periodicCov(fragments, 2*readLen, l) * (p1 - p0) * (p1 - p0)
+		if math.Abs(cc.GetResult(l)-expected) > 1e-12 {
+			t.Errorf("quality-weighted, lag %d: expect %g, got %g\n", l, expected, cc.GetResult(l))
+		}
+	}
+}
+
+func TestAlleleFreqsCross(t *testing.T) {
+	length, readLen, maxl := 40, 4, 8
+	g, variant := crossGenomes(length)
+	sample1 := tileReads(g.Seq, readLen, 2)
+
+	// all reads of the second sample come from the variant.
+	sample2 := tileReads(variant, readLen, 1)
+	kc, cc := AlleleFreqsCross(sample1, sample2, g, maxl, 4)
+	if ks := kc.Mean.GetResult(); math.Abs(ks-0.25) > 1e-12 {
+		t.Errorf("expect Ks 0.25, got %g\n", ks)
+	}
+	for l := 1; l < maxl; l++ {
+		expected := periodicCov(1, length, l)
+		if math.Abs(cc.GetResult(l)-expected) > 1e-12 {
+			t.Errorf("lag %d: expect %g, got %g\n", l, expected, cc.GetResult(l))
+		}
+	}
+
+	// half of the reads come from the variant.
+	sample2 = append(sample2, tileReads(g.Seq, readLen, 1)...)
+	kc, cc = AlleleFreqsCross(sample1, sample2, g, maxl, 4)
+	if ks := kc.Mean.GetResult(); math.Abs(ks-0.125) > 1e-12 {
+		t.Errorf("expect Ks 0.125, got %g\n", ks)
+	}
+	if expected := periodicCov(1, length, 4) / 4; math.Abs(cc.GetResult(4)-expected) > 1e-12 {
+		t.Errorf("expect %g, got %g\n", expected, cc.GetResult(4))
+	}
+}
+
+func TestAlleleDiff(t *testing.T) {
+	values := []struct {
+		c1, c2   [4]int32
+		expected float64
+	}{
+		{[4]int32{2, 0, 0, 0}, [4]int32{3, 0, 0, 0}, 0},
+		{[4]int32{2, 0, 0, 0}, [4]int32{0, 3, 0, 0}, 1},
+		{[4]int32{1, 1, 0, 0}, [4]int32{1, 1, 0, 0}, 0.5},
+		{[4]int32{1, 0, 0, 3}, [4]int32{0, 0, 0, 2}, 0.25},
+	}
+	for i, v := range values {
+		if d := alleleDiff(v.c1, v.c2); math.Abs(d-v.expected) > 1e-12 {
+			t.Errorf("%d, expect %g, got %g\n", i, v.expected, d)
+		}
+	}
+	if d := alleleDiff([4]int32{}, [4]int32{1, 0, 0, 0}); !math.IsNaN(d) {
+		t.Errorf("expect NaN without reads, got %g\n", d)
+	}
+}
+
+// two-pass covariance.
+func covariance(xs, ys []float64, bias bool) float64 {
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	n := float64(len(xs))
+	mx /= n
+	my /= n
+	var c float64
+	for i := range xs {
+		c += (xs[i] - mx) * (ys[i] - my)
+	}
+	if bias {
+		return c / (n - 1)
+	}
+	return c / n
+}
@@ -0,0 +1,120 @@
+package cov
+
+import (
+	"github.com/mingzhi/meta/reads"
+	"math"
+)
+
+// MateErrorCalculator estimates the per-base sequencing error rate,
+// from mismatches between the overlapping parts of paired-end mates,
+// which are copies of the same DNA fragment.
+type MateErrorCalculator struct {
+	Mismatches int // number of mismatched sites.
+	Sites      int // number of compared sites.
+}
+
+// NewMateErrorCalculator return a new MateErrorCalculator.
+func NewMateErrorCalculator() *MateErrorCalculator {
+	return &MateErrorCalculator{}
+}
+
+// Increment compares two aligned sequences site by site.
+// Sites with invalid nucleotides are skipped.
+func (m *MateErrorCalculator) Increment(a, b []byte) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if isValidNucl(a[i]) && isValidNucl(b[i]) {
+			m.Sites++
+			if a[i] != b[i] {
+				m.Mismatches++
+			}
+		}
+	}
+}
+
+// Append merges another MateErrorCalculator.
+func (m *MateErrorCalculator) Append(m2 *MateErrorCalculator) {
+	m.Mismatches += m2.Mismatches
+	m.Sites += m2.Sites
+}
+
+// Rate returns the per-base error rate e,
+// by solving 2e - 4e^2/3 = mismatch rate,
+// in which both mates have the same error rate
+// and errors are uniform over the other three bases.
+func (m *MateErrorCalculator) Rate() float64 {
+	if m.Sites == 0 {
+		return math.NaN()
+	}
+	d := float64(m.Mismatches) / float64(m.Sites)
+	if d >= 0.75 {
+		return 0.75
+	}
+	return 0.75 * (1 - math.Sqrt(1-4.0*d/3.0))
+}
+
+// EstimateMateError estimates the sequencing error rate
+// from overlapping paired-end mates.
+func EstimateMateError(matedReads reads.PairedEndReads) *MateErrorCalculator {
+	m := NewMateErrorCalculator()
+	for _, r := range matedReads {
+		left := reads.Map2Ref(r.ReadLeft)
+		right := reads.Map2Ref(r.ReadRight)
+		start := maxInt(r.ReadLeft.Pos, r.ReadRight.Pos)
+		end := minInt(r.ReadLeft.Pos+len(left), r.ReadRight.Pos+len(right))
+		if end > start {
+			a := left[start-r.ReadLeft.Pos : end-r.ReadLeft.Pos]
+			b := right[start-r.ReadRight.Pos : end-r.ReadRight.Pos]
+			m.Increment(a, b)
+		}
+	}
+	return m
+}
+
+// ErrorModel corrects substitution statistics for sequencing errors,
+// in which errors at different sites are independent.
+type ErrorModel struct {
+	A float64 // probability of observing a difference at an identical site.
+	B float64 // probability of observing no difference at a different site.
+}
+
+// NewErrorModel returns an ErrorModel for comparing two sequences
+// with per-base error rates e1 and e2.
+// Use zero for a sequence without error, such as the reference genome.
+func NewErrorModel(e1, e2 float64) *ErrorModel {
+	sameIfSame := (1-e1)*(1-e2) + e1*e2/3.0
+	sameIfDiff := (1-e1)*e2/3.0 + e1*(1-e2)/3.0 + e1*e2*2.0/9.0
+	return &ErrorModel{A: 1 - sameIfSame, B: sameIfDiff}
+}
+
+// scale returns the attenuation of a substitution indicator.
+func (m *ErrorModel) scale() float64 {
+	return 1 - m.A - m.B
+}
+
+// CorrectKs returns the error-corrected Ks from the observed one.
+func (m *ErrorModel) CorrectKs(ks float64) float64 {
+	return (ks - m.A) / m.scale()
+}
+
+// CorrectCov returns the error-corrected covariance
+// between two different sites from the observed one.
+func (m *ErrorModel) CorrectCov(c float64) float64 {
+	return c / (m.scale() * m.scale())
+}
+
+// CorrectJoint returns the error-corrected joint probability
+// of differences at two different sites, given the observed one
+// and the observed Ks.
+func (m *ErrorModel) CorrectJoint(p, ks float64) float64 {
+	c := m.scale()
+	return (p - m.A*m.A - 2*m.A*c*m.CorrectKs(ks)) / (c * c)
+}
+
+// CorrectProduct returns the mean product of error-corrected diversities
+// of two sites, given the observed mean product p
+// and the observed mean diversity of the two sites,
+// in which the diversity of each site is corrected separately.
+func (m *ErrorModel) CorrectProduct(p, mean float64) float64 {
+	c := m.scale()
+	return (p - 2*m.A*mean + m.A*m.A) / (c * c)
+}
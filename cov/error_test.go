@@ -0,0 +1,71 @@
+package cov
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMateErrorRate(t *testing.T) {
+	m := NewMateErrorCalculator()
+	if !math.IsNaN(m.Rate()) {
+		t.Errorf("expect NaN without sites, got %g\n", m.Rate())
+	}
+
+	m.Increment([]byte("ACGTACGTNA"), []byte("ACGAACGT*C"))
+	if m.Sites != 9 || m.Mismatches != 2 {
+		t.Errorf("expect 2 mismatches in 9 sites, got %d in %d\n", m.Mismatches, m.Sites)
+	}
+
+	// the mismatch rate of two mates with error rate e is 2e - 4e^2/3.
+	for _, e := range []float64{0, 0.001, 0.01, 0.1} {
+		d := 2*e - 4*e*e/3
+		m := MateErrorCalculator{Mismatches: int(d * 1e9), Sites: 1e9}
+		if math.Abs(m.Rate()-e) > 1e-8 {
+			t.Errorf("expect error rate %g, got %g\n", e, m.Rate())
+		}
+	}
+
+	m = &MateErrorCalculator{Mismatches: 8, Sites: 10}
+	if m.Rate() != 0.75 {
+		t.Errorf("expect error rate 0.75 for saturated mismatches, got %g\n", m.Rate())
+	}
+}
+
+func TestErrorModelWithoutErrors(t *testing.T) {
+	m := NewErrorModel(0, 0)
+	for _, ks := range []float64{0, 0.01, 0.5} {
+		if m.CorrectKs(ks) != ks {
+			t.Errorf("expect %g, got %g\n", ks, m.CorrectKs(ks))
+		}
+	}
+}
+
+func TestCorrectKs(t *testing.T) {
+	ks := 0.02
+	for _, e := range [][]float64{{0.01, 0}, {0.01, 0.01}, {0.001, 0.005}} {
+		m := NewErrorModel(e[0], e[1])
+		// observed differences at identical and different sites.
+		observed := (1-ks)*m.A + ks*(1-m.B)
+		if v := m.CorrectKs(observed); math.Abs(v-ks) > 1e-12 {
+			t.Errorf("%v: expect %g, got %g\n", e, ks, v)
+		}
+	}
+}
+
+func TestCorrectCovAndProduct(t *testing.T) {
+	m := NewErrorModel(0.01, 0.01)
+	c := 1 - m.A - m.B
+
+	// diversities of two sites, and their observed values.
+	x, y := 0.02, 0.05
+	ox, oy := m.A+c*x, m.A+c*y
+	if v := m.CorrectProduct(ox*oy, (ox+oy)/2); math.Abs(v-x*y) > 1e-12 {
+		t.Errorf("expect product %g, got %g\n", x*y, v)
+	}
+
+	// covariance is attenuated by the square of the scale.
+	cov := 0.001
+	if v := m.CorrectCov(cov * c * c); math.Abs(v-cov) > 1e-12 {
+		t.Errorf("expect covariance %g, got %g\n", cov, v)
+	}
+}
@@ -38,6 +38,29 @@ func SubCorr(subs []float64, cc *CovCalculator, kc *KsCalculator, maxl int) {
 	}
 }
 
+// Calculate weighted correlations of subsitutions,
+// in which a pair of sites is weighted by the product of their weights.
+func SubCorrWeighted(subs, weights []float64, cc *CovCalculator, kc *KsCalculator, maxl int) {
+	ints := getPosIndices(subs)
+	for _, i := range ints {
+		kc.Increment(subs[i])
+		cc.IncrementWeighted(0, subs[i], subs[i], weights[i])
+	}
+
+	for j := 0; j < len(ints); j++ {
+		for k := j + 1; k < len(ints); k++ {
+			l := ints[k] - ints[j]
+			if l >= maxl {
+				break
+			} else {
+				x, y := subs[ints[j]], subs[ints[k]]
+				w := weights[ints[j]] * weights[ints[k]]
+				cc.IncrementWeighted(l, x, y, w)
+			}
+		}
+	}
+}
+
 // Calculate structure covariance for a subsitution matrix.
 func SubMatrixSCov(subMatrix [][]float64, cs *MeanCovCalculator, maxl int) {
 	ints := getPosIndices(subMatrix[0])
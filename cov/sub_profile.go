@@ -9,29 +9,16 @@ const (
 	AlphabetDNA = "ATGCatgc"
 )
 
+// Default prior probability of a true difference at a site,
+// used by quality-weighted substitution profiles.
+const DefaultSubPrior = 0.01
+
 // Generate substitution profile according to the position profile.
 func SubProfile(read, nucl, profile []byte, pos int) []float64 {
-	// determine the codon position.
-	var cp byte
-	switch pos {
-	case 1:
-		cp = genome.FirstPos
-	case 2:
-		cp = genome.SecondPos
-	case 3:
-		cp = genome.ThirdPos
-	case 4:
-		cp = genome.FourFold
-	}
+	cp := codonPos(pos)
 	subs := make([]float64, len(profile))
 	for i := 0; i < len(subs); i++ {
-		p := profile[i]
-		var match bool
-		if cp == genome.ThirdPos {
-			match = p == genome.ThirdPos || p == genome.FourFold
-		} else {
-			match = p == cp
-		}
+		match := matchPos(profile[i], cp)
 		valid := isValidNucl(read[i]) && isValidNucl(nucl[i])
 		if match && valid {
 			if read[i] == nucl[i] {
@@ -46,6 +33,92 @@ func SubProfile(read, nucl, profile []byte, pos int) []float64 {
 	return subs
 }
 
+// Generate quality-weighted substitution profile according to the position profile.
+// Each site gets the posterior probability of a true difference,
+// and a weight of the probability that both base calls are correct.
+// A nil quality means that the sequence has no sequencing error,
+// such as the reference genome.
+// prior is the prior probability of a true difference.
+func SubProfileQual(read, nucl, readQual, nuclQual, profile []byte, pos int, prior float64) (subs, weights []float64) {
+	cp := codonPos(pos)
+	subs = make([]float64, len(profile))
+	weights = make([]float64, len(profile))
+	for i := 0; i < len(subs); i++ {
+		match := matchPos(profile[i], cp)
+		valid := isValidNucl(read[i]) && isValidNucl(nucl[i])
+		if match && valid {
+			e1, e2 := 0.0, 0.0
+			if readQual != nil {
+				e1 = PhredErr(readQual[i])
+			}
+			if nuclQual != nil {
+				e2 = PhredErr(nuclQual[i])
+			}
+			subs[i] = DiffProb(read[i] != nucl[i], e1, e2, prior)
+			weights[i] = (1 - e1) * (1 - e2)
+		} else {
+			subs[i] = math.NaN()
+		}
+	}
+	return
+}
+
+// PhredErr converts a Phred quality score to an error probability.
+func PhredErr(q byte) float64 {
+	return math.Pow(10, -float64(q)/10.0)
+}
+
+// DiffProb returns the posterior probability of a true difference
+// between two bases, given whether the observed bases differ,
+// their error probabilities e1 and e2,
+// and the prior probability of a true difference.
+// Sequencing errors are assumed to be uniform over the other three bases.
+func DiffProb(observedDiff bool, e1, e2, prior float64) float64 {
+	// probability of observing the same base,
+	// given the true bases are the same or different.
+	m := NewErrorModel(e1, e2)
+	sameIfSame := 1 - m.A
+	sameIfDiff := m.B
+
+	var likeSame, likeDiff float64
+	if observedDiff {
+		likeSame = 1 - sameIfSame
+		likeDiff = 1 - sameIfDiff
+	} else {
+		likeSame = sameIfSame
+		likeDiff = sameIfDiff
+	}
+
+	total := prior*likeDiff + (1-prior)*likeSame
+	if total == 0 {
+		return math.NaN()
+	}
+	return prior * likeDiff / total
+}
+
+// determine the codon position.
+func codonPos(pos int) (cp byte) {
+	switch pos {
+	case 1:
+		cp = genome.FirstPos
+	case 2:
+		cp = genome.SecondPos
+	case 3:
+		cp = genome.ThirdPos
+	case 4:
+		cp = genome.FourFold
+	}
+	return
+}
+
+// check if a position profile matches the codon position.
+func matchPos(p, cp byte) bool {
+	if cp == genome.ThirdPos {
+		return p == genome.ThirdPos || p == genome.FourFold
+	}
+	return p == cp
+}
+
 func isValidNucl(r byte) bool {
 	for i := 0; i < len(AlphabetDNA); i++ {
 		if AlphabetDNA[i] == r {
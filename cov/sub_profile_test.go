@@ -0,0 +1,65 @@
+package cov
+
+import (
+	"github.com/mingzhi/meta/genome"
+	"math"
+	"testing"
+)
+
+func TestDiffProbWithoutErrors(t *testing.T) {
+	if p := DiffProb(true, 0, 0, DefaultSubPrior); p != 1 {
+		t.Errorf("expect 1 for an observed difference, got %g\n", p)
+	}
+	if p := DiffProb(false, 0, 0, DefaultSubPrior); p != 0 {
+		t.Errorf("expect 0 for an observed identity, got %g\n", p)
+	}
+}
+
+func TestDiffProb(t *testing.T) {
+	values := []struct {
+		observedDiff bool
+		e1, e2       float64
+		expected     float64
+	}{
+		{true, 0.01, 0, 0.5016778523489933},
+		{true, 0, 0.01, 0.5016778523489933},
+		{false, 0.01, 0, 3.400897837028976e-05},
+	}
+	for i, v := range values {
+		p := DiffProb(v.observedDiff, v.e1, v.e2, 0.01)
+		if math.Abs(p-v.expected) > 1e-12 {
+			t.Errorf("%d, expect %g, got %g\n", i, v.expected, p)
+		}
+	}
+}
+
+func TestDiffProbPrior(t *testing.T) {
+	if p := DiffProb(true, 0.01, 0.01, 0); p != 0 {
+		t.Errorf("expect 0 with a zero prior, got %g\n", p)
+	}
+	if p := DiffProb(false, 0.01, 0.01, 1); p != 1 {
+		t.Errorf("expect 1 with a prior of one, got %g\n", p)
+	}
+	// a larger error rate weakens the evidence of an observed difference.
+	if DiffProb(true, 0.05, 0.05, DefaultSubPrior) >= DiffProb(true, 0.01, 0.01, DefaultSubPrior) {
+		t.Errorf("expect smaller posterior with larger errors\n")
+	}
+}
+
+func TestSubProfileQualWithoutQualities(t *testing.T) {
+	read := []byte("ACGTA")
+	nucl := []byte("ACCTN")
+	ff := genome.FourFold
+	profile := []byte{ff, ff, ff, genome.FirstPos, ff}
+	subs, weights := SubProfileQual(read, nucl, nil, nil, profile, 4, DefaultSubPrior)
+	expected := SubProfile(read, nucl, profile, 4)
+	for i := range subs {
+		same := subs[i] == expected[i] || (math.IsNaN(subs[i]) && math.IsNaN(expected[i]))
+		if !same {
+			t.Errorf("%d, expect %g, got %g\n", i, expected[i], subs[i])
+		}
+		if !math.IsNaN(subs[i]) && weights[i] != 1 {
+			t.Errorf("%d, expect weight 1, got %g\n", i, weights[i])
+		}
+	}
+}
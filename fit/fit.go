@@ -1,35 +1,27 @@
 package fit
 
-// #cgo LDFLAGS: -lm
-// #include "fit.h"
-import "C"
 import (
-	"github.com/mingzhi/gomath/stat/regression"
-
-	"unsafe"
+	"math"
 )
 
 func FitHyper(t, y []float64) []float64 {
-	s := regression.NewSimple()
-	for i := 0; i < 10; i++ {
-		if i >= len(y) {
-			break
-		}
-		iy := 1.0 / y[i]
-		s.Add(t[i], iy)
-	}
+	return FitHyperSE(t, y, nil).Params
+}
 
-	n := 2
-	m := len(t)
-	par := []float64{s.Intercept(), s.Slope()}
-	C.fitHyper(C.int(n), (*C.double)(unsafe.Pointer(&par[0])), C.int(m), (*C.double)(unsafe.Pointer(&t[0])), (*C.double)(unsafe.Pointer(&y[0])))
+func FitExp(t, y []float64) []float64 {
+	return FitExpSE(t, y, nil).Params
+}
 
-	return par
+// FitHyperSE fits the hyperbolic model, weighted by the standard errors se.
+// Use nil se for unweighted fitting.
+func FitHyperSE(t, y, se []float64) (res Result) {
+	res.fit(hyperModel, hyperJac, t, y, se, hyperGuess(t, y), nil, nil)
+	return
 }
 
-func FitExp(t, y []float64) []float64 {
-	n := 3
-	m := len(t)
+// FitExpSE fits the exponential model, weighted by the standard errors se.
+// Use nil se for unweighted fitting.
+func FitExpSE(t, y, se []float64) (res Result) {
 	l := 6
 	if len(t) < l {
 		l = len(t)
@@ -37,7 +29,29 @@ func FitExp(t, y []float64) []float64 {
 	par := FitHyper(t[:l], y[:l])
 	par[1] = par[1] * 100
 	par = append(par, 100.0)
-	C.fitExp(C.int(n), (*C.double)(unsafe.Pointer(&par[0])), C.int(m), (*C.double)(unsafe.Pointer(&t[0])), (*C.double)(unsafe.Pointer(&y[0])))
 
-	return par
+	res.fit(expModel, expJac, t, y, se, par, nil, nil)
+	return
+}
+
+func hyperModel(t float64, p []float64) float64 {
+	return 1.0 / (p[0] + p[1]*t)
+}
+
+func hyperJac(t float64, p, grad []float64) {
+	d := p[0] + p[1]*t
+	grad[0] = -1 / (d * d)
+	grad[1] = -t / (d * d)
+}
+
+func expModel(t float64, p []float64) float64 {
+	return 1.0 / (p[0] + p[1]*(1-math.Exp(-t/p[2])))
+}
+
+func expJac(t float64, p, grad []float64) {
+	e := math.Exp(-t / p[2])
+	d := p[0] + p[1]*(1-e)
+	grad[0] = -1 / (d * d)
+	grad[1] = -(1 - e) / (d * d)
+	grad[2] = p[1] * e * t / (p[2] * p[2]) / (d * d)
 }
@@ -1,4 +0,0 @@
-#include "lmstruct.h"
-#include "lmcurve.h"
-int fitHyper(int n, double *par, int m, double *t, double *y);
-int fitExp(int n, double *par, int m, double *t, double *y);
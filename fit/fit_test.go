@@ -31,3 +31,135 @@ func TestFitExp(t *testing.T) {
 		}
 	}
 }
+
+func TestFitRecomb(t *testing.T) {
+	ks, theta, phi, f := 0.05, 0.06, 0.002, 300.0
+	a := ks * ks / (1 + 2*jcA*theta)
+	expected := RecombResult{Amplitude: a, Phi: phi, Fragment: f}
+	l, cs := []float64{}, []float64{}
+	for i := 0; i < 100; i++ {
+		l = append(l, float64(3*i))
+		cs = append(cs, expected.Cs(float64(3*i)))
+	}
+	res := FitRecomb(ks, l, cs)
+	values := [][]float64{{theta, res.Theta}, {phi, res.Phi}, {f, res.Fragment}, {phi * f, res.Rho}, {phi / theta, res.Ratio}}
+	for i, v := range values {
+		if math.Abs(v[1]-v[0]) > 1e-3*v[0] {
+			t.Errorf("%d, Expect %f, got %f\n", i, v[0], v[1])
+		}
+	}
+}
+
+func TestFitHyperSE(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	y := []float64{0.01560084, 0.01392907, 0.01301867, 0.01249557, 0.01190561, 0.01131878, 0.01091478, 0.01073981, 0.01043296}
+	// uniform errors give the unweighted fit.
+	se := StdErrFromCount([]int{4, 4, 4, 4, 4, 4, 4, 4, 4})
+	res := FitHyperSE(x, y, se)
+	par, gof := res.Params, res.GoodnessOfFit
+	expected := FitHyper(x, y)
+	for i := 0; i < len(par); i++ {
+		if math.Abs(par[i]-expected[i]) > 1e-5 {
+			t.Errorf("%d, Expect %f, got %f\n", i, expected[i], par[i])
+		}
+	}
+	if !res.Converged {
+		t.Errorf("Expect converged fit, got %s\n", res.Message)
+	}
+	if gof.DoF != len(x)-2 || len(gof.Residuals) != len(x) {
+		t.Errorf("Expect %d degrees of freedom and %d residuals, got %d and %d\n", len(x)-2, len(x), gof.DoF, len(gof.Residuals))
+	}
+}
+
+func TestFitExpSENaN(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	y := []float64{0.01560084, 0.01392907, math.NaN(), 0.01249557, 0.01190561, 0.01131878, 0.01091478, 0.01073981, 0.01043296}
+	res := FitExpSE(x, y, nil)
+	if res.Converged || res.Message != "non-finite chi-square" {
+		t.Errorf("Expect failed fit of NaN data, got converged %v, %s\n", res.Converged, res.Message)
+	}
+}
+
+func TestModelHyper(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	y := []float64{0.01560084, 0.01392907, 0.01301867, 0.01249557, 0.01190561, 0.01131878, 0.01091478, 0.01073981, 0.01043296}
+	m, found := Lookup("hyper")
+	if !found {
+		t.Fatal("hyper model is not registered")
+	}
+	res := m.Fit(x, y, nil)
+	expected := []float64{62.352885, 4.122212}
+	for i := 0; i < len(expected); i++ {
+		if math.Abs(res.Params[i]-expected[i]) > 1e-4 {
+			t.Errorf("%d, Expect %f, got %f\n", i, expected[i], res.Params[i])
+		}
+	}
+	if !res.Converged {
+		t.Error("Expect converged fit")
+	}
+	if math.IsNaN(res.AIC) || math.IsNaN(res.BIC) {
+		t.Errorf("Expect AIC and BIC, got %f and %f\n", res.AIC, res.BIC)
+	}
+}
+
+func TestProfileCI(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	y := []float64{0.01560084, 0.01392907, 0.01301867, 0.01249557, 0.01190561, 0.01131878, 0.01091478, 0.01073981, 0.01043296}
+	m, _ := Lookup("hyper")
+	res := m.Fit(x, y, nil)
+	lower, upper := m.ProfileCI(x, y, nil, res.Result, 0.95)
+	for i, p := range res.Params {
+		if !(lower[i] < p && p < upper[i]) {
+			t.Errorf("%d, Expect %f in (%f, %f)\n", i, p, lower[i], upper[i])
+		}
+		// the model is nearly linear in parameters,
+		// so that the interval is close to +/- 1.96 SE.
+		if math.Abs((upper[i]-lower[i])/(2*1.96*res.SE[i])-1) > 0.1 {
+			t.Errorf("%d, Expect width %f, got %f\n", i, 2*1.96*res.SE[i], upper[i]-lower[i])
+		}
+	}
+}
+
+func TestJointFit(t *testing.T) {
+	m, _ := Lookup("exp")
+	// three strains sharing the decay length b2.
+	pars := [][]float64{{60, 150, 40}, {80, 120, 40}, {50, 200, 40}}
+	datasets := []Dataset{}
+	for _, p := range pars {
+		ds := Dataset{}
+		for i := 1; i <= 100; i++ {
+			ds.T = append(ds.T, float64(i))
+			ds.Y = append(ds.Y, expModel(float64(i), p))
+		}
+		datasets = append(datasets, ds)
+	}
+
+	res, err := m.JointFit(datasets, []string{"b2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.SharedParams) != 1 || math.Abs(res.SharedParams[0]-40) > 1e-3 {
+		t.Errorf("Expect shared b2 40, got %v\n", res.SharedParams)
+	}
+	for d, p := range pars {
+		for j := range p {
+			if math.Abs(res.Params[d][j]-p[j]) > 1e-3*p[j] {
+				t.Errorf("%d, %d, Expect %f, got %f\n", d, j, p[j], res.Params[d][j])
+			}
+		}
+	}
+
+	if _, err := m.JointFit(datasets, []string{"b3"}); err == nil {
+		t.Errorf("Expect an error of unknown shared parameter b3\n")
+	}
+}
+
+func TestStdErrFromProportion(t *testing.T) {
+	se := StdErrFromProportion([]float64{0.5, 0.1, 0, 0.2}, []int{100, 100, 100, 0})
+	expected := []float64{0.05, 0.03, 0.03, math.Inf(1)}
+	for i := range expected {
+		if math.Abs(se[i]-expected[i]) > 1e-12 && se[i] != expected[i] {
+			t.Errorf("%d, Expect %f, got %f\n", i, expected[i], se[i])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package fit
+
+import (
+	"math"
+)
+
+// GoodnessOfFit contains goodness-of-fit statistics.
+type GoodnessOfFit struct {
+	Chi2      float64   // sum of squared residuals, weighted by standard errors.
+	DoF       int       // degrees of freedom.
+	RedChi2   float64   // reduced chi-square, Chi2 / DoF.
+	Residuals []float64 // y - f(t).
+}
+
+// Goodness calculates goodness-of-fit statistics of a model
+// with parameters par, given data points with standard errors se.
+// Use nil se for unweighted data, whose Chi2 is the residual sum of squares.
+// Points with infinite standard errors do not count.
+func Goodness(model func(t float64, par []float64) float64, t, y, se, par []float64) (gof GoodnessOfFit) {
+	m := 0
+	for i := range t {
+		r := y[i] - model(t[i], par)
+		gof.Residuals = append(gof.Residuals, r)
+		if se == nil {
+			gof.Chi2 += r * r
+			m++
+		} else if !math.IsInf(se[i], 1) {
+			gof.Chi2 += (r / se[i]) * (r / se[i])
+			m++
+		}
+	}
+	gof.DoF = m - len(par)
+	if gof.DoF > 0 {
+		gof.RedChi2 = gof.Chi2 / float64(gof.DoF)
+	} else {
+		gof.RedChi2 = math.NaN()
+	}
+	return
+}
+
+// StdErrFromVar returns standard errors of means sqrt(v/n),
+// from variances v and numbers n of observations.
+// Lags without observations have infinite errors;
+// zero or NaN errors are raised to the smallest positive one.
+func StdErrFromVar(v []float64, n []int) []float64 {
+	se := make([]float64, len(v))
+	for i := range v {
+		if n[i] > 0 {
+			se[i] = math.Sqrt(v[i] / float64(n[i]))
+		} else {
+			se[i] = math.Inf(1)
+		}
+	}
+	floorStdErr(se)
+	return se
+}
+
+// StdErrFromProportion returns standard errors sqrt(p(1-p)/n)
+// of proportions p of n binary observations,
+// such as products of substitution indicators of site pairs.
+// Lags without observations have infinite errors;
+// zero or NaN errors are raised to the smallest positive one.
+func StdErrFromProportion(p []float64, n []int) []float64 {
+	v := make([]float64, len(p))
+	for i := range p {
+		q := math.Min(math.Max(p[i], 0), 1)
+		v[i] = q * (1 - q)
+	}
+	return StdErrFromVar(v, n)
+}
+
+// StdErrFromCount returns relative standard errors 1/sqrt(n),
+// for means of unknown variances.
+// They only give relative weights, so that Chi2 of fits weighted by them
+// is not a chi-square statistic.
+func StdErrFromCount(n []int) []float64 {
+	se := make([]float64, len(n))
+	for i := range n {
+		if n[i] > 0 {
+			se[i] = 1.0 / math.Sqrt(float64(n[i]))
+		} else {
+			se[i] = math.Inf(1)
+		}
+	}
+	return se
+}
+
+func floorStdErr(se []float64) {
+	min := math.Inf(1)
+	for _, v := range se {
+		if v > 0 && v < min {
+			min = v
+		}
+	}
+	for i, v := range se {
+		if v <= 0 || math.IsNaN(v) {
+			se[i] = min
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package fit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Dataset is a set of data points, such as the correlation profile of a strain.
+type Dataset struct {
+	T, Y []float64
+	SE   []float64 // standard errors, nil for unweighted.
+}
+
+// JointResult is the result of fitting a model jointly to datasets,
+// in which shared parameters take the same values in all datasets.
+type JointResult struct {
+	Model        string
+	Shared       []string  // names of shared parameters.
+	SharedParams []float64 // estimates of shared parameters.
+	SharedSE     []float64
+
+	// Parameters and standard errors of each dataset,
+	// including shared ones, in the order of model parameters.
+	Params [][]float64
+	SE     [][]float64
+
+	Status
+	GoodnessOfFit
+	AIC, BIC float64
+}
+
+// JointFit fits the model to all datasets jointly,
+// sharing the parameters named in shared,
+// while the other parameters vary among datasets.
+// Independent fits of datasets give the initial parameters,
+// and the medians of them are the initial shared parameters.
+// It returns an error if a shared name is not a parameter of the model.
+func (m *Model) JointFit(datasets []Dataset, shared []string) (res JointResult, err error) {
+	n := len(m.Params)
+	isShared := make([]bool, n)
+	for _, name := range shared {
+		found := false
+		for j, p := range m.Params {
+			if p == name {
+				isShared[j] = true
+				found = true
+			}
+		}
+		if !found {
+			return res, fmt.Errorf("fit: model %s has no parameter %s", m.Name, name)
+		}
+	}
+
+	// layout of the global parameter vector:
+	// shared parameters, followed by local parameters of each dataset.
+	index := make([]int, n) // position among shared or local parameters.
+	nShared, nLocal := 0, 0
+	for j := 0; j < n; j++ {
+		if isShared[j] {
+			index[j] = nShared
+			nShared++
+		} else {
+			index[j] = nLocal
+			nLocal++
+		}
+	}
+	global := func(d, j int) int {
+		if isShared[j] {
+			return index[j]
+		}
+		return nShared + d*nLocal + index[j]
+	}
+	nGlobal := nShared + len(datasets)*nLocal
+
+	// flatten data points, whose t is the index of the point.
+	var owner []int
+	var realT, t, y, se []float64
+	weighted := false
+	for _, ds := range datasets {
+		if ds.SE != nil {
+			weighted = true
+		}
+	}
+	for d, ds := range datasets {
+		for i := range ds.T {
+			owner = append(owner, d)
+			realT = append(realT, ds.T[i])
+			t = append(t, float64(len(t)))
+			y = append(y, ds.Y[i])
+			if weighted {
+				if ds.SE != nil {
+					se = append(se, ds.SE[i])
+				} else {
+					se = append(se, 1)
+				}
+			}
+		}
+	}
+
+	local := func(d int, par []float64) []float64 {
+		p := make([]float64, n)
+		for j := 0; j < n; j++ {
+			p[j] = par[global(d, j)]
+		}
+		return p
+	}
+	f := func(ti float64, par []float64) float64 {
+		i := int(ti)
+		return m.Func(realT[i], local(owner[i], par))
+	}
+	var jac func(ti float64, par, grad []float64)
+	if m.Jac != nil {
+		jac = func(ti float64, par, grad []float64) {
+			i := int(ti)
+			d := owner[i]
+			g := make([]float64, n)
+			m.Jac(realT[i], local(d, par), g)
+			for j := range grad {
+				grad[j] = 0
+			}
+			for j := 0; j < n; j++ {
+				grad[global(d, j)] = g[j]
+			}
+		}
+	}
+
+	// bounds and initial parameters.
+	lower := make([]float64, nGlobal)
+	upper := make([]float64, nGlobal)
+	par0 := make([]float64, nGlobal)
+	sharedInits := make([][]float64, n)
+	for d, ds := range datasets {
+		var p []float64
+		if len(ds.T) > n {
+			p = m.Fit(ds.T, ds.Y, ds.SE).Params
+		} else {
+			p = m.Guess(ds.T, ds.Y)
+		}
+		for j := 0; j < n; j++ {
+			k := global(d, j)
+			lower[k], upper[k] = math.Inf(-1), math.Inf(1)
+			if m.Lower != nil {
+				lower[k] = m.Lower[j]
+			}
+			if m.Upper != nil {
+				upper[k] = m.Upper[j]
+			}
+			if isShared[j] {
+				sharedInits[j] = append(sharedInits[j], p[j])
+			} else {
+				par0[k] = p[j]
+			}
+		}
+	}
+	for j := 0; j < n; j++ {
+		if isShared[j] {
+			par0[index[j]] = median(sharedInits[j])
+		}
+	}
+
+	var r Result
+	r.fit(f, jac, t, y, se, par0, lower, upper)
+	par := r.Params
+
+	res.Model = m.Name
+	res.Status = r.Status
+	res.GoodnessOfFit = r.GoodnessOfFit
+	res.AIC, res.BIC = InfoCriteria(res.Chi2, res.DoF, nGlobal)
+	for j := 0; j < n; j++ {
+		if isShared[j] {
+			res.Shared = append(res.Shared, m.Params[j])
+			res.SharedParams = append(res.SharedParams, par[index[j]])
+			res.SharedSE = append(res.SharedSE, r.SE[index[j]])
+		}
+	}
+	for d := range datasets {
+		p := make([]float64, n)
+		s := make([]float64, n)
+		for j := 0; j < n; j++ {
+			p[j] = par[global(d, j)]
+			s[j] = r.SE[global(d, j)]
+		}
+		res.Params = append(res.Params, p)
+		res.SE = append(res.SE, s)
+	}
+	return
+}
+
+// median of values, NaN if empty.
+func median(values []float64) float64 {
+	sorted := []float64{}
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(sorted)
+	k := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[k-1] + sorted[k]) / 2
+	}
+	return sorted[k]
+}
@@ -0,0 +1,135 @@
+package fit
+
+import (
+	"math"
+)
+
+// Invert a square matrix by Gauss-Jordan elimination with partial pivoting.
+// It returns false if the matrix is singular.
+func invert(m [][]float64) (inv [][]float64, ok bool) {
+	n := len(m)
+	a := make([][]float64, n)
+	inv = make([][]float64, n)
+	for i := 0; i < n; i++ {
+		a[i] = make([]float64, n)
+		copy(a[i], m[i])
+		inv[i] = make([]float64, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if a[pivot][col] == 0 || math.IsNaN(a[pivot][col]) {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		d := a[col][col]
+		for j := 0; j < n; j++ {
+			a[col][j] /= d
+			inv[col][j] /= d
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row][col]
+			for j := 0; j < n; j++ {
+				a[row][j] -= factor * a[col][j]
+				inv[row][j] -= factor * inv[col][j]
+			}
+		}
+	}
+
+	return inv, true
+}
+
+// Numerical jacobian of a model at data points t,
+// by central differences.
+func jacobian(model func(t float64, par []float64) float64, t, par []float64) [][]float64 {
+	jac := make([][]float64, len(t))
+	for i := range t {
+		jac[i] = make([]float64, len(par))
+	}
+
+	p := make([]float64, len(par))
+	for j := range par {
+		h := 1e-6 * math.Max(math.Abs(par[j]), 1e-8)
+		for i := range t {
+			copy(p, par)
+			p[j] = par[j] + h
+			y1 := model(t[i], p)
+			p[j] = par[j] - h
+			y2 := model(t[i], p)
+			jac[i][j] = (y1 - y2) / (2 * h)
+		}
+	}
+
+	return jac
+}
+
+// Covariance matrix of least-squares parameter estimates,
+// s^2 (J^T W J)^-1, in which W are the weights 1/se^2
+// and s^2 is the reduced chi-square.
+// It returns false if it cannot be estimated.
+func paramCov(model func(t float64, par []float64) float64, t, y, se, par []float64) (cov [][]float64, ok bool) {
+	n, m := len(par), len(t)
+	gof := Goodness(model, t, y, se, par)
+	if gof.DoF <= 0 {
+		return nil, false
+	}
+	s2 := gof.RedChi2
+
+	jac := jacobian(model, t, par)
+	jtj := make([][]float64, n)
+	for a := 0; a < n; a++ {
+		jtj[a] = make([]float64, n)
+	}
+	for i := 0; i < m; i++ {
+		w := 1.0
+		if se != nil {
+			w = 1.0 / (se[i] * se[i])
+		}
+		for a := 0; a < n; a++ {
+			if jac[i][a] == 0 {
+				continue
+			}
+			for b := 0; b < n; b++ {
+				jtj[a][b] += w * jac[i][a] * jac[i][b]
+			}
+		}
+	}
+
+	cov, ok = invert(jtj)
+	if !ok {
+		return nil, false
+	}
+	for a := 0; a < n; a++ {
+		for b := 0; b < n; b++ {
+			cov[a][b] *= s2
+		}
+	}
+
+	return cov, true
+}
+
+// Standard error of a derived quantity g(par) by the delta method,
+// given the gradient of g and the parameter covariance.
+func deltaSE(grad []float64, cov [][]float64) float64 {
+	v := 0.0
+	for a := range grad {
+		for b := range grad {
+			v += grad[a] * cov[a][b] * grad[b]
+		}
+	}
+	if v < 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(v)
+}
@@ -0,0 +1,193 @@
+package fit
+
+import (
+	"math"
+)
+
+// Controls of the Levenberg-Marquardt algorithm.
+var (
+	LMMaxIter = 1000  // maximum number of iterations.
+	LMFtol    = 1e-12 // relative reduction of chi-square for convergence.
+	LMXtol    = 1e-10 // relative change of parameters for convergence.
+)
+
+// Messages of Levenberg-Marquardt minimizations.
+const (
+	lmConverged     = "converged"
+	lmCallLimit     = "call limit"
+	lmNoImprovement = "no improvement"
+	lmNonFinite     = "non-finite chi-square"
+)
+
+// Status of a Levenberg-Marquardt minimization.
+type lmStatus struct {
+	Converged  bool
+	Iterations int
+	Chi2       float64
+	Message    string
+}
+
+// Weighted least-squares fitting by the Levenberg-Marquardt algorithm,
+// with parameters kept in bounds [lower, upper] by projection.
+// jac calculates the gradient of f with respect to parameters,
+// or is nil for numerical differentiation.
+func levenbergMarquardt(f func(t float64, par []float64) float64,
+	jac func(t float64, par, grad []float64),
+	t, y, se, par0, lower, upper []float64) (par []float64, status lmStatus) {
+
+	n, m := len(par0), len(t)
+	par = make([]float64, n)
+	copy(par, par0)
+	clamp(par, lower, upper)
+
+	weights := make([]float64, m)
+	for i := range weights {
+		weights[i] = 1
+		if se != nil {
+			weights[i] = 1 / (se[i] * se[i])
+		}
+	}
+
+	chi2 := func(p []float64) float64 {
+		s := 0.0
+		for i := range t {
+			if weights[i] > 0 {
+				r := y[i] - f(t[i], p)
+				s += weights[i] * r * r
+			}
+		}
+		return s
+	}
+
+	grad := make([]float64, n)
+	gradient := func(ti float64, p []float64) {
+		if jac != nil {
+			jac(ti, p, grad)
+			return
+		}
+		copy(grad, jacobian(f, []float64{ti}, p)[0])
+	}
+
+	// parameters fixed by equal bounds.
+	fixed := make([]bool, n)
+	for j := range fixed {
+		fixed[j] = lower != nil && upper != nil && lower[j] == upper[j]
+	}
+
+	nonzero := make([]int, 0, n)
+	lambda := 1e-3
+	status.Chi2 = chi2(par)
+	if math.IsNaN(status.Chi2) || math.IsInf(status.Chi2, 0) {
+		status.Message = lmNonFinite
+		return
+	}
+	if status.Chi2 == 0 {
+		// an exact fit.
+		status.Converged = true
+		status.Message = lmConverged
+		return
+	}
+	for status.Iterations = 1; status.Iterations <= LMMaxIter; status.Iterations++ {
+		// normal equations.
+		a := make([][]float64, n)
+		for j := range a {
+			a[j] = make([]float64, n)
+		}
+		g := make([]float64, n)
+		for i := range t {
+			if weights[i] == 0 {
+				continue
+			}
+			gradient(t[i], par)
+			r := y[i] - f(t[i], par)
+			// skip zero derivatives, as in joint fits.
+			nonzero = nonzero[:0]
+			for j := 0; j < n; j++ {
+				if grad[j] != 0 {
+					nonzero = append(nonzero, j)
+				}
+			}
+			for _, j := range nonzero {
+				g[j] += weights[i] * grad[j] * r
+				for _, k := range nonzero {
+					a[j][k] += weights[i] * grad[j] * grad[k]
+				}
+			}
+		}
+		for j := 0; j < n; j++ {
+			if fixed[j] {
+				g[j] = 0
+				for k := 0; k < n; k++ {
+					a[j][k], a[k][j] = 0, 0
+				}
+				a[j][j] = 1
+			}
+		}
+
+		improved := false
+		for !improved && lambda < 1e16 {
+			damped := make([][]float64, n)
+			for j := range a {
+				damped[j] = make([]float64, n)
+				copy(damped[j], a[j])
+				damped[j][j] += lambda * math.Max(a[j][j], 1e-12)
+			}
+			inv, ok := invert(damped)
+			if !ok {
+				lambda *= 10
+				continue
+			}
+
+			trial := make([]float64, n)
+			for j := 0; j < n; j++ {
+				trial[j] = par[j]
+				for k := 0; k < n; k++ {
+					trial[j] += inv[j][k] * g[k]
+				}
+			}
+			clamp(trial, lower, upper)
+
+			c := chi2(trial)
+			if c < status.Chi2 {
+				improved = true
+				lambda /= 10
+				dx := 0.0
+				for j := 0; j < n; j++ {
+					dx = math.Max(dx, math.Abs(trial[j]-par[j])/math.Max(math.Abs(par[j]), 1e-12))
+				}
+				df := (status.Chi2 - c) / math.Max(status.Chi2, 1e-300)
+				copy(par, trial)
+				status.Chi2 = c
+				if df < LMFtol || dx < LMXtol || c == 0 {
+					status.Converged = true
+					status.Message = lmConverged
+					return
+				}
+			} else {
+				lambda *= 10
+			}
+		}
+
+		if !improved {
+			// no step reduces chi-square, even with the largest damping.
+			status.Message = lmNoImprovement
+			return
+		}
+	}
+	status.Iterations = LMMaxIter
+	status.Message = lmCallLimit
+
+	return
+}
+
+// Keep parameters in bounds.
+func clamp(par, lower, upper []float64) {
+	for i := range par {
+		if lower != nil && par[i] < lower[i] {
+			par[i] = lower[i]
+		}
+		if upper != nil && par[i] > upper[i] {
+			par[i] = upper[i]
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package fit
+
+import (
+	"github.com/mingzhi/gomath/stat/regression"
+
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Model is a curve model of correlation profiles.
+type Model struct {
+	Name   string
+	Params []string // names of parameters.
+
+	// Bounds of parameters, nil for unbounded.
+	Lower, Upper []float64
+
+	// Func returns the model value at t.
+	Func func(t float64, par []float64) float64
+	// Jac writes the gradient of Func with respect to parameters into grad,
+	// or is nil for numerical differentiation.
+	Jac func(t float64, par, grad []float64)
+	// Guess returns initial parameters from data.
+	Guess func(t, y []float64) []float64
+}
+
+// ModelFit is the result of fitting a model.
+type ModelFit struct {
+	Model string
+	Result
+
+	// Information criteria,
+	// from the (weighted) residual sum of squares.
+	AIC, BIC float64
+}
+
+var registry = make(map[string]*Model)
+
+// Register adds a model to the registry.
+// It panics if a model of the same name has been registered.
+func Register(m *Model) {
+	if _, found := registry[m.Name]; found {
+		panic(fmt.Sprintf("fit: model %s registered twice", m.Name))
+	}
+	registry[m.Name] = m
+}
+
+// Lookup returns the model registered by name.
+func Lookup(name string) (m *Model, found bool) {
+	m, found = registry[name]
+	return
+}
+
+// Models returns names of registered models in sorted order.
+func Models() (names []string) {
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Fit fits the model to data points,
+// weighted by the standard errors se, or unweighted if se is nil.
+func (m *Model) Fit(t, y, se []float64) (res ModelFit) {
+	res.Model = m.Name
+	par0 := m.Guess(t, y)
+	res.fit(m.Func, m.Jac, t, y, se, par0, m.Lower, m.Upper)
+	res.AIC, res.BIC = InfoCriteria(res.Chi2, res.DoF, len(res.Params))
+	return
+}
+
+// InfoCriteria returns AIC and BIC of a least-squares fit
+// from the (weighted) residual sum of squares chi2,
+// with dof degrees of freedom and k parameters.
+func InfoCriteria(chi2 float64, dof, k int) (aic, bic float64) {
+	n := float64(dof + k)
+	aic = n*math.Log(chi2/n) + 2*float64(k)
+	bic = n*math.Log(chi2/n) + float64(k)*math.Log(n)
+	return
+}
+
+func init() {
+	Register(&Model{
+		Name:   "hyper",
+		Params: []string{"b0", "b1"},
+		Func:   hyperModel,
+		Jac:    hyperJac,
+		Guess:  hyperGuess,
+	})
+
+	Register(&Model{
+		Name:   "exp",
+		Params: []string{"b0", "b1", "b2"},
+		Lower:  []float64{math.Inf(-1), math.Inf(-1), 1e-6},
+		Upper:  []float64{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Func:   expModel,
+		Jac:    expJac,
+		Guess:  expGuess,
+	})
+
+	Register(&Model{
+		Name:   "double_exp",
+		Params: []string{"b0", "b1", "b2", "b3", "b4"},
+		Lower:  []float64{math.Inf(-1), math.Inf(-1), 1e-6, math.Inf(-1), 1e-6},
+		Upper:  []float64{math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(1)},
+		Func:   doubleExpModel,
+		Jac: func(t float64, p, grad []float64) {
+			e1 := math.Exp(-t / p[2])
+			e2 := math.Exp(-t / p[4])
+			d := p[0] + p[1]*(1-e1) + p[3]*(1-e2)
+			grad[0] = -1 / (d * d)
+			grad[1] = -(1 - e1) / (d * d)
+			grad[2] = p[1] * e1 * t / (p[2] * p[2]) / (d * d)
+			grad[3] = -(1 - e2) / (d * d)
+			grad[4] = p[3] * e2 * t / (p[4] * p[4]) / (d * d)
+		},
+		Guess: func(t, y []float64) []float64 {
+			// split the exponential decay into a fast and a slow one.
+			p := expGuess(t, y)
+			return []float64{p[0], p[1] / 2, p[2] / 5, p[1] / 2, p[2] * 5}
+		},
+	})
+}
+
+func doubleExpModel(t float64, p []float64) float64 {
+	return 1.0 / (p[0] + p[1]*(1-math.Exp(-t/p[2])) + p[3]*(1-math.Exp(-t/p[4])))
+}
+
+// Linear regression of 1/y on t over the first 10 points.
+func hyperGuess(t, y []float64) []float64 {
+	s := regression.NewSimple()
+	for i := 0; i < 10 && i < len(y); i++ {
+		s.Add(t[i], 1.0/y[i])
+	}
+	return []float64{s.Intercept(), s.Slope()}
+}
+
+// Similar to the initial guess of FitExp.
+func expGuess(t, y []float64) []float64 {
+	l := 6
+	if len(t) < l {
+		l = len(t)
+	}
+	par := hyperGuess(t[:l], y[:l])
+	return []float64{par[0], par[1] * 100, 100.0}
+}
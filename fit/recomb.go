@@ -0,0 +1,220 @@
+package fit
+
+import (
+	"math"
+)
+
+// Saturation constant of the Jukes-Cantor model.
+const jcA = 4.0 / 3.0
+
+// RecombResult contains population-genetic parameters
+// inferred from a correlation profile, and their standard errors.
+//
+// Two genomes at a site differ with probability p(T) = 3/4 (1 - exp(-aθT)),
+// where T ~ Exp(1) is their coalescence time, so that the diversity is
+// d = θ / (1 + aθ). Two sites separated by l share T until a recombination
+// event covers one but not the other, which happens at rate
+// r(l) = 2φf(1 - exp(-l/f)), with φ the rate of events starting at a site
+// and f the mean (exponentially distributed) length of transferred fragments.
+// The linkage covariance is then Cs(l) = d^2 / (1 + 2aθ) / (1 + r(l)),
+// and the total covariance is Ct(l) = Cs(l) + Cr,
+// where Cr is the distance-independent covariance of mutation rates.
+type RecombResult struct {
+	Ks         float64 // sample diversity d.
+	Theta      float64 // mutation rate θ.
+	Phi        float64 // rate of recombination events starting at a site.
+	Rho        float64 // rate at which a site is covered by recombination, φf.
+	Ratio      float64 // ratio of recombination to mutation, φ/θ.
+	Fragment   float64 // mean length of transferred fragments f.
+	Amplitude  float64 // linkage covariance at zero distance.
+	Background float64 // distance-independent covariance Cr.
+
+	// Standard errors.
+	ThetaSE, PhiSE, RhoSE, RatioSE, FragmentSE, AmplitudeSE float64
+
+	N    int     // number of data points.
+	Chi2 float64 // weighted sum of squared residuals.
+	DoF  int     // degrees of freedom.
+
+	// Convergence status.
+	Status
+}
+
+// Cs returns the expected linkage covariance at distance l.
+func (r RecombResult) Cs(l float64) float64 {
+	return recombCs(l, []float64{r.Amplitude, r.Phi, r.Fragment})
+}
+
+// Cr returns the expected covariance of mutation rates at distance l.
+func (r RecombResult) Cr(l float64) float64 {
+	return r.Background
+}
+
+// Ct returns the expected total covariance at distance l.
+func (r RecombResult) Ct(l float64) float64 {
+	return r.Cs(l) + r.Cr(l)
+}
+
+// Linkage covariance, par: amplitude, phi and fragment length.
+func recombCs(l float64, par []float64) float64 {
+	return par[0] / (1 + 2*par[1]*par[2]*(1-math.Exp(-l/par[2])))
+}
+
+// Linkage covariance, par: amplitude, log(phi) and log(fragment length),
+// which keeps phi and fragment length positive in fitting.
+func recombLogModel(l float64, par []float64) float64 {
+	return recombCs(l, []float64{par[0], math.Exp(par[1]), math.Exp(par[2])})
+}
+
+// FitRecomb fits the linkage covariance cs at distances l (in bp),
+// given the sample diversity ks.
+// Zero distance, at which cs is the variance, is skipped.
+func FitRecomb(ks float64, l, cs []float64) (res RecombResult) {
+	return FitRecombSE(ks, l, cs, nil)
+}
+
+// FitRecombSE is FitRecomb weighted by the standard errors se of cs.
+// Use nil se for unweighted fitting.
+func FitRecombSE(ks float64, l, cs, se []float64) (res RecombResult) {
+	t, y := []float64{}, []float64{}
+	var dy []float64
+	for i := range l {
+		if l[i] > 0 && !math.IsNaN(cs[i]) {
+			t = append(t, l[i])
+			y = append(y, cs[i])
+			if se != nil {
+				dy = append(dy, se[i])
+			}
+		}
+	}
+
+	res.Ks = ks
+	res.N = len(t)
+	res.Theta, res.Phi, res.Rho, res.Ratio, res.Fragment, res.Amplitude = nan6()
+	res.ThetaSE, res.PhiSE, res.RhoSE, res.RatioSE, res.FragmentSE, res.AmplitudeSE = nan6()
+	if len(t) < 4 {
+		return
+	}
+
+	par, status := levenbergMarquardt(recombLogModel, nil, t, y, dy, recombGuess(t, y), nil, nil)
+	res.Status = newStatus(status)
+	natural := []float64{par[0], math.Exp(par[1]), math.Exp(par[2])}
+
+	a, phi, f := natural[0], natural[1], natural[2]
+	res.Amplitude = a
+	res.Phi = phi
+	res.Fragment = f
+	res.Rho = phi * f
+	res.Theta = (ks*ks/a - 1) / (2 * jcA)
+	if res.Theta <= 0 {
+		res.Theta = math.NaN()
+	}
+	res.Ratio = phi / res.Theta
+	gof := Goodness(recombCs, t, y, dy, natural)
+	res.Chi2 = gof.Chi2
+	res.DoF = gof.DoF
+
+	cov, ok := paramCov(recombCs, t, y, dy, natural)
+	if !ok {
+		return
+	}
+	res.AmplitudeSE = math.Sqrt(cov[0][0])
+	res.PhiSE = math.Sqrt(cov[1][1])
+	res.FragmentSE = math.Sqrt(cov[2][2])
+	res.RhoSE = deltaSE([]float64{0, f, phi}, cov)
+	dTheta := -ks * ks / (2 * jcA * a * a)
+	res.ThetaSE = deltaSE([]float64{dTheta, 0, 0}, cov)
+	theta := res.Theta
+	res.RatioSE = deltaSE([]float64{-phi / (theta * theta) * dTheta, 1 / theta, 0}, cov)
+
+	return
+}
+
+// FitRecombCt fits the total covariance ct, with the covariance
+// of mutation rates cr at the same distances l (in bp),
+// weighted by the standard errors se of ct, or unweighted if se is nil.
+func FitRecombCt(ks float64, l, ct, cr, se []float64) (res RecombResult) {
+	cs := make([]float64, len(ct))
+	for i := range ct {
+		cs[i] = ct[i] - cr[i]
+	}
+	res = FitRecombSE(ks, l, cs, se)
+	res.Background = meanPositive(l, cr)
+	return
+}
+
+// FitRecombP2 fits P2 and P4 from meta_p2 at distances l (in bp),
+// in which P2(0) is the sample diversity,
+// P2(l) - P4(l) is the linkage covariance,
+// and P4(l) - P2(0)^2 is the covariance of mutation rates,
+// weighted by the standard errors se of P2, or unweighted if se is nil.
+func FitRecombP2(l, p2, p4, se []float64) (res RecombResult) {
+	ks := math.NaN()
+	for i := range l {
+		if l[i] == 0 {
+			ks = p2[i]
+		}
+	}
+
+	cs := make([]float64, len(p2))
+	cr := make([]float64, len(p2))
+	for i := range p2 {
+		cs[i] = p2[i] - p4[i]
+		cr[i] = p4[i] - ks*ks
+	}
+	res = FitRecombSE(ks, l, cs, se)
+	res.Background = meanPositive(l, cr)
+	return
+}
+
+// Initial guess of (amplitude, log(phi), log(fragment length)),
+// from the first value, the tail and the half-decay distance.
+func recombGuess(t, y []float64) []float64 {
+	a := y[0]
+	tail := 0.0
+	k := len(y) / 4
+	if k == 0 {
+		k = 1
+	}
+	for _, v := range y[len(y)-k:] {
+		tail += v
+	}
+	tail /= float64(k)
+	if tail <= 0 || tail >= a {
+		tail = a / 2
+	}
+
+	half := t[len(t)-1]
+	for i := range t {
+		if y[i] < (a+tail)/2 {
+			half = t[i]
+			break
+		}
+	}
+	f := half / math.Ln2
+	if f < 1 {
+		f = 1
+	}
+	rho := (a/tail - 1) / 2
+	return []float64{a, math.Log(rho / f), math.Log(f)}
+}
+
+// Mean of values at positive distances.
+func meanPositive(l, values []float64) float64 {
+	sum, n := 0.0, 0
+	for i := range values {
+		if l[i] > 0 && !math.IsNaN(values[i]) {
+			sum += values[i]
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+func nan6() (a, b, c, d, e, f float64) {
+	n := math.NaN()
+	return n, n, n, n, n, n
+}
@@ -0,0 +1,155 @@
+package fit
+
+import (
+	"math"
+)
+
+// Status is the convergence status of a fit.
+type Status struct {
+	Converged    bool
+	Iterations   int     // number of Levenberg-Marquardt iterations.
+	ResidualNorm float64 // norm of the (weighted) residual vector.
+	Message      string
+}
+
+// Result is the result of a least-squares fit.
+type Result struct {
+	Params []float64
+	Status
+	GoodnessOfFit
+	Cov [][]float64 // covariance of parameters, nil if it cannot be estimated.
+	SE  []float64   // standard errors of parameters.
+}
+
+// Status from a Levenberg-Marquardt status.
+func newStatus(s lmStatus) (status Status) {
+	status.Converged = s.Converged
+	status.Iterations = s.Iterations
+	status.ResidualNorm = math.Sqrt(s.Chi2)
+	status.Message = s.Message
+	return
+}
+
+// Fit a model by the Levenberg-Marquardt algorithm from initial parameters par0,
+// with bounds [lower, upper] (nil for unbounded),
+// and fill the parameters, status and estimates of the result.
+func (r *Result) fit(model func(t float64, par []float64) float64,
+	jac func(t float64, par, grad []float64),
+	t, y, se, par0, lower, upper []float64) {
+	par, status := levenbergMarquardt(model, jac, t, y, se, par0, lower, upper)
+	r.Params = par
+	r.Status = newStatus(status)
+	r.estimate(model, t, y, se)
+}
+
+// Fill goodness of fit, covariance and standard errors of a result,
+// whose parameters and status have been set.
+func (r *Result) estimate(model func(t float64, par []float64) float64, t, y, se []float64) {
+	r.GoodnessOfFit = Goodness(model, t, y, se, r.Params)
+	r.SE = make([]float64, len(r.Params))
+	cov, ok := paramCov(model, t, y, se, r.Params)
+	if !ok {
+		for i := range r.SE {
+			r.SE[i] = math.NaN()
+		}
+		return
+	}
+	r.Cov = cov
+	for i := range r.SE {
+		r.SE[i] = math.Sqrt(cov[i][i])
+	}
+}
+
+// ProfileCI returns profile-likelihood confidence intervals
+// of the parameters of a fitted model at a confidence level (e.g. 0.95).
+// For each parameter, the others are refitted with it fixed,
+// and the bounds are where chi-square increases by the
+// chi-square quantile of the level times the reduced chi-square.
+// A bound is infinite (or the model bound) if chi-square does not increase enough.
+func (m *Model) ProfileCI(t, y, se []float64, res Result, level float64) (lower, upper []float64) {
+	n := len(res.Params)
+	z := math.Sqrt2 * math.Erfinv(level)
+	s2 := res.RedChi2
+	if math.IsNaN(s2) || s2 <= 0 {
+		s2 = 1
+	}
+	threshold := res.Chi2 + z*z*s2
+
+	// chi-square with the j-th parameter fixed at v.
+	profile := func(j int, v float64) float64 {
+		lo, hi := make([]float64, n), make([]float64, n)
+		for k := 0; k < n; k++ {
+			lo[k], hi[k] = math.Inf(-1), math.Inf(1)
+			if m.Lower != nil {
+				lo[k] = m.Lower[k]
+			}
+			if m.Upper != nil {
+				hi[k] = m.Upper[k]
+			}
+		}
+		lo[j], hi[j] = v, v
+		par0 := make([]float64, n)
+		copy(par0, res.Params)
+		par0[j] = v
+		_, status := levenbergMarquardt(m.Func, m.Jac, t, y, se, par0, lo, hi)
+		return status.Chi2
+	}
+
+	// search a bound in a direction (1 or -1).
+	bound := func(j int, direction float64) float64 {
+		p := res.Params[j]
+		limit := math.Inf(int(direction))
+		if direction < 0 && m.Lower != nil {
+			limit = m.Lower[j]
+		} else if direction > 0 && m.Upper != nil {
+			limit = m.Upper[j]
+		}
+
+		step := math.NaN()
+		if res.SE != nil {
+			step = res.SE[j]
+		}
+		if math.IsNaN(step) || step <= 0 || math.IsInf(step, 0) {
+			step = 0.1 * math.Max(math.Abs(p), 1e-6)
+		}
+
+		in, out := p, p
+		exceeded := false
+		for k := 0; k < 50 && !exceeded; k++ {
+			out = p + direction*step
+			if direction*(out-limit) >= 0 {
+				out = limit
+				if math.IsInf(limit, 0) || profile(j, out) <= threshold {
+					return limit
+				}
+				exceeded = true
+			} else if profile(j, out) > threshold {
+				exceeded = true
+			} else {
+				in = out
+				step *= 2
+			}
+		}
+		if !exceeded {
+			return limit
+		}
+
+		for k := 0; k < 30; k++ {
+			mid := (in + out) / 2
+			if profile(j, mid) > threshold {
+				out = mid
+			} else {
+				in = mid
+			}
+		}
+		return (in + out) / 2
+	}
+
+	lower = make([]float64, n)
+	upper = make([]float64, n)
+	for j := 0; j < n; j++ {
+		lower[j] = bound(j, -1)
+		upper[j] = bound(j, 1)
+	}
+	return
+}
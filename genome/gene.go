@@ -0,0 +1,44 @@
+package genome
+
+import (
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"path/filepath"
+)
+
+// Gene feature on a genome.
+type Gene struct {
+	From   int    // 1-based start position.
+	To     int    // 1-based end position.
+	Strand string // "+" or "-".
+}
+
+// Load protein-coding genes to the genome,
+// from the .ptt file in base folder.
+// Genes across the origin are skipped.
+func LoadGenes(g *Genome, base string) {
+	fileName := filepath.Join(base, g.RefAcc()+".ptt")
+	pttFile := seqrecord.NewPttFile(fileName)
+	g.Genes = nil
+	for _, ptt := range pttFile.ReadAll() {
+		if ptt.Loc.To >= ptt.Loc.From {
+			gene := Gene{From: ptt.Loc.From, To: ptt.Loc.To, Strand: ptt.Loc.Strand}
+			g.Genes = append(g.Genes, gene)
+		}
+	}
+}
+
+// GeneIndex returns the index of the gene covering each genome position,
+// or -1 for non-coding positions.
+// For overlapping genes, the later one is used.
+func (g Genome) GeneIndex() []int {
+	indices := make([]int, len(g.PosProfile))
+	for i := range indices {
+		indices[i] = -1
+	}
+	for i, gene := range g.Genes {
+		for j := gene.From - 1; j < gene.To && j < len(indices); j++ {
+			indices[j] = i
+		}
+	}
+	return indices
+}
@@ -10,6 +10,7 @@ type Genome struct {
 	Length     int     // length of the genome.
 	Seq        []byte  // genome sequence.
 	PosProfile Profile // position profile.
+	Genes      []Gene  // protein-coding genes.
 }
 
 type Profile []byte
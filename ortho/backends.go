@@ -0,0 +1,131 @@
+package ortho
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Blastp searches with BLAST+ blastp.
+type Blastp struct {
+	Options SearchOptions
+}
+
+// MakeDB indexes the sequence db with makeblastdb.
+func (b Blastp) MakeDB(db string) error {
+	if _, err := os.Stat(db + ".phr"); err == nil {
+		return nil
+	}
+	return run(exec.Command("makeblastdb", "-in", db, "-dbtype", "prot", "-out", db))
+}
+
+// Search runs blastp, filtering identity and coverage afterwards.
+// It asks for at least 5 targets, since -max_target_seqs 1
+// may not report the best hit.
+func (b Blastp) Search(q, db string) ([]Hit, error) {
+	return runSearch("blastp", func(out string) []string {
+		return b.args(q, db, out)
+	}, b.Options)
+}
+
+// args returns arguments of blastp writing hits to the out file.
+func (b Blastp) args(q, db, out string) []string {
+	opts := b.Options
+	args := []string{"-query", q, "-db", db, "-out", out,
+		"-outfmt", "6 " + strings.Join(BlastColumns, " "),
+		"-max_target_seqs", fmt.Sprintf("%d", maxInt(opts.MaxTargets, 5)),
+		"-num_threads", fmt.Sprintf("%d", opts.Threads)}
+	if opts.EValue > 0 {
+		args = append(args, "-evalue", fmt.Sprintf("%g", opts.EValue))
+	}
+	return args
+}
+
+// Diamond searches with DIAMOND blastp.
+type Diamond struct {
+	Options SearchOptions
+}
+
+// MakeDB indexes the sequence db with diamond makedb.
+func (d Diamond) MakeDB(db string) error {
+	if _, err := os.Stat(db + ".dmnd"); err == nil {
+		return nil
+	}
+	return run(exec.Command("diamond", "makedb", "--in", db, "--db", db+".dmnd"))
+}
+
+// Search runs diamond blastp.
+func (d Diamond) Search(q, db string) ([]Hit, error) {
+	return runSearch("diamond", func(out string) []string {
+		return d.args(q, db, out)
+	}, d.Options)
+}
+
+// args returns arguments of diamond blastp writing hits to the out file.
+func (d Diamond) args(q, db, out string) []string {
+	opts := d.Options
+	args := []string{"blastp", "--query", q, "--db", db + ".dmnd", "--out", out,
+		"--outfmt", "6"}
+	args = append(args, BlastColumns...)
+	args = append(args,
+		"--id", fmt.Sprintf("%g", 100*opts.Identity),
+		"--query-cover", fmt.Sprintf("%g", 100*opts.Coverage),
+		"--subject-cover", fmt.Sprintf("%g", 100*opts.SCoverage),
+		"--max-target-seqs", fmt.Sprintf("%d", opts.MaxTargets),
+		"--threads", fmt.Sprintf("%d", opts.Threads))
+	if opts.EValue > 0 {
+		args = append(args, "--evalue", fmt.Sprintf("%g", opts.EValue))
+	}
+	return args
+}
+
+// MMseqs searches with MMseqs2 easy-search.
+type MMseqs struct {
+	Options SearchOptions
+}
+
+// MakeDB does nothing, since easy-search indexes the db itself.
+func (m MMseqs) MakeDB(db string) error { return nil }
+
+// Search runs mmseqs easy-search, with a temporary working folder.
+func (m MMseqs) Search(q, db string) ([]Hit, error) {
+	opts := m.Options
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "mmseqs")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	return runSearch("mmseqs", func(out string) []string {
+		return m.args(q, db, out, tmpDir)
+	}, opts)
+}
+
+// names of mmseqs output fields, in the order of BlastColumns.
+var mmseqsFields = []string{"query", "target", "pident", "alnlen", "mismatch", "gapopen",
+	"qstart", "qend", "tstart", "tend", "evalue", "bits", "qlen", "tlen"}
+
+// args returns arguments of mmseqs easy-search writing hits to the out file,
+// with the temporary working folder.
+func (m MMseqs) args(q, db, out, tmpDir string) []string {
+	opts := m.Options
+	args := []string{"easy-search", q, db, out, tmpDir,
+		"--format-output", strings.Join(mmseqsFields, ","),
+		"--min-seq-id", fmt.Sprintf("%g", opts.Identity),
+		"-c", fmt.Sprintf("%g", opts.Coverage), "--cov-mode", "2",
+		"--max-accept", fmt.Sprintf("%d", opts.MaxTargets),
+		"--threads", fmt.Sprintf("%d", opts.Threads)}
+	if opts.EValue > 0 {
+		args = append(args, "-e", fmt.Sprintf("%g", opts.EValue))
+	}
+	return args
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
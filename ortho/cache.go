@@ -0,0 +1,153 @@
+package ortho
+
+// Caching of search results on disk.
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CachedBackend caches hits of a search backend in a folder,
+// one file for each pair of query and db files,
+// keyed by their content hashes and the backend with its options affecting hits.
+// Reruns skip searches that have completed,
+// and changed sequence files are searched again.
+type CachedBackend struct {
+	Backend SearchBackend
+	Dir     string
+}
+
+// NewCachedBackend returns a backend caching hits in the folder,
+// which is created if it does not exist.
+func NewCachedBackend(backend SearchBackend, dir string) (*CachedBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &CachedBackend{Backend: backend, Dir: dir}, nil
+}
+
+// MakeDB indexes the sequence db with the backend.
+func (c CachedBackend) MakeDB(db string) error {
+	return c.Backend.MakeDB(db)
+}
+
+// Search returns cached hits, or searches and caches the hits.
+func (c CachedBackend) Search(q, db string) ([]Hit, error) {
+	key, err := c.key(q, db)
+	if err != nil {
+		return nil, err
+	}
+	fileName := filepath.Join(c.Dir, key+".tsv")
+	if _, err := os.Stat(fileName); err == nil {
+		hits, err := readHits(fileName, FullID)
+		if err == nil {
+			return hits, nil
+		}
+		log.Printf("Search again for corrupted cache: %v\n", err)
+	}
+
+	hits, err := c.Backend.Search(q, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHits(fileName, hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// key returns the hash of the query and db contents,
+// and the backend with its options affecting hits.
+func (c CachedBackend) key(q, db string) (string, error) {
+	qHash, err := fileHash(q)
+	if err != nil {
+		return "", err
+	}
+	dbHash, err := fileHash(db)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "%#v\n%s\n%s\n", resultOptions(c.Backend), qHash, dbHash)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resultOptions returns the backend without options not affecting hits,
+// such as the number of threads.
+func resultOptions(backend SearchBackend) SearchBackend {
+	switch b := backend.(type) {
+	case Usearch:
+		b.Options.Threads = 0
+		return b
+	case Blastp:
+		b.Options.Threads = 0
+		return b
+	case Diamond:
+		b.Options.Threads = 0
+		return b
+	case MMseqs:
+		b.Options.Threads = 0
+		return b
+	}
+	return backend
+}
+
+// fileHashes memorizes hashes of files, which are hashed once per run.
+var fileHashes = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// fileHash returns the hex sha1 hash of the file content.
+func fileHash(fileName string) (string, error) {
+	fileHashes.Lock()
+	defer fileHashes.Unlock()
+	if s, found := fileHashes.m[fileName]; found {
+		return s, nil
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	s := hex.EncodeToString(h.Sum(nil))
+	fileHashes.m[fileName] = s
+	return s, nil
+}
+
+// writeHits writes hits in the search format,
+// into a temp file renamed when completed,
+// so that an interrupted run does not leave partial results.
+func writeHits(fileName string, hits []Hit) error {
+	temp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName))
+	if err != nil {
+		return err
+	}
+	for _, h := range hits {
+		evalue := "*"
+		if !math.IsNaN(h.EValue) {
+			evalue = fmt.Sprintf("%g", h.EValue)
+		}
+		fmt.Fprintf(temp, "%s\t%s\t%g\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%g\t%d\t%d\n",
+			h.QSeqid, h.SSeqid, h.PIdent, h.Length, h.Mismatch, h.GapOpen,
+			h.QStart, h.QEnd, h.SStart, h.SEnd, evalue, h.BitScore, h.QLen, h.SLen)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), fileName)
+}
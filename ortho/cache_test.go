@@ -0,0 +1,88 @@
+package ortho
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// countBackend returns fixed hits and counts searches.
+type countBackend struct {
+	hits  []Hit
+	count *int
+}
+
+func (b countBackend) MakeDB(db string) error { return nil }
+func (b countBackend) Search(q, db string) ([]Hit, error) {
+	*b.count++
+	return b.hits, nil
+}
+
+func TestCachedBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ortho_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	q := filepath.Join(dir, "a.faa")
+	db := filepath.Join(dir, "b.faa")
+	ioutil.WriteFile(q, []byte(">a1\nMKV\n"), 0644)
+	ioutil.WriteFile(db, []byte(">b1\nMKI\n"), 0644)
+
+	count := 0
+	hits := []Hit{{QSeqid: "a1", SSeqid: "b1", PIdent: 66.7, Length: 3, Mismatch: 1,
+		QStart: 1, QEnd: 3, SStart: 1, SEnd: 3, EValue: 1e-5, BitScore: 12.5, QLen: 3, SLen: 3}}
+	backend, err := NewCachedBackend(countBackend{hits, &count}, filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := backend.Search(q, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := backend.Search(q, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("searches: got %d, want 1\n", count)
+	}
+	if !reflect.DeepEqual(first, hits) || !reflect.DeepEqual(second, hits) {
+		t.Errorf("cached hits: got %v, want %v\n", second, hits)
+	}
+	backend.Search(db, q)
+	if count != 2 {
+		t.Errorf("searches of swapped files: got %d, want 2\n", count)
+	}
+}
+
+func TestCacheKeyThreads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ortho_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	q := filepath.Join(dir, "a.faa")
+	ioutil.WriteFile(q, []byte(">a1\nMKV\n"), 0644)
+
+	key := func(opts SearchOptions) string {
+		c := CachedBackend{Backend: Diamond{opts}, Dir: dir}
+		k, err := c.key(q, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	opts := SearchOptions{Identity: 0.8, MaxTargets: 1, Threads: 1}
+	k1 := key(opts)
+	opts.Threads = 8
+	if k2 := key(opts); k2 != k1 {
+		t.Errorf("keys of different threads: got %s and %s\n", k1, k2)
+	}
+	opts.Identity = 0.9
+	if k3 := key(opts); k3 == k1 {
+		t.Errorf("keys of different identities: got the same %s\n", k1)
+	}
+}
@@ -0,0 +1,87 @@
+package ortho
+
+// Classification of ortholog alignments into core and dispensable ones.
+
+import (
+	"github.com/mingzhi/meta/strain"
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"math"
+)
+
+// Types of ortholog alignments, used in names of outputs.
+const (
+	CoreAlignment    = "core"
+	DispAlignment    = "disp"
+	ParalogAlignment = "paralog"
+	PanAlignment     = "pan"
+)
+
+// CorePolicy decides which ortholog alignments are core.
+type CorePolicy struct {
+	Presence   float64 // min fraction of strains having core alignments.
+	SingleCopy bool    // core alignments have at most one gene in each strain.
+	Paralogs   bool    // separate alignments with multiple genes in a strain as paralog.
+}
+
+// DefaultCorePolicy takes alignments present in all strains as core.
+var DefaultCorePolicy = CorePolicy{Presence: 1.0}
+
+// Types returns types of alignments: core, disp, paralog if separated,
+// and pan of all alignments.
+func (p CorePolicy) Types() []string {
+	if p.Paralogs {
+		return []string{CoreAlignment, DispAlignment, ParalogAlignment, PanAlignment}
+	}
+	return []string{CoreAlignment, DispAlignment, PanAlignment}
+}
+
+// Classify returns the type of an alignment present in n of total strains,
+// with at most maxCopies genes in a strain.
+func (p CorePolicy) Classify(n, maxCopies, total int) string {
+	if p.Paralogs && maxCopies > 1 {
+		return ParalogAlignment
+	}
+	if p.SingleCopy && maxCopies > 1 {
+		return DispAlignment
+	}
+	// the min number of strains, tolerating rounding errors of fractions.
+	minStrains := int(math.Ceil(p.Presence*float64(total) - 1e-9))
+	if n >= minStrains {
+		return CoreAlignment
+	}
+	return DispAlignment
+}
+
+// Separate groups alignments by types, in which pan has all alignments.
+// Records are assigned to strains by their genome accessions,
+// and records of unknown genomes are counted as separate strains.
+func (p CorePolicy) Separate(alignments []seqrecord.SeqRecords, strains []strain.Strain) map[string][]seqrecord.SeqRecords {
+	index := make(map[string]int)
+	for i, s := range strains {
+		for _, g := range s.Genomes {
+			index[g.Accession] = i
+			index[g.RefAcc()] = i
+		}
+	}
+
+	groups := make(map[string][]seqrecord.SeqRecords)
+	for _, aln := range alignments {
+		copies := make(map[interface{}]int)
+		maxCopies := 0
+		for _, rec := range aln {
+			var key interface{} = rec.Genome
+			if i, found := index[rec.Genome]; found {
+				key = i
+			}
+			copies[key]++
+			if copies[key] > maxCopies {
+				maxCopies = copies[key]
+			}
+		}
+		typ := p.Classify(len(copies), maxCopies, len(strains))
+		groups[typ] = append(groups[typ], aln)
+		groups[PanAlignment] = append(groups[PanAlignment], aln)
+	}
+
+	return groups
+}
@@ -0,0 +1,27 @@
+package ortho
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCorePolicy(t *testing.T) {
+	p := CorePolicy{Presence: 0.95}
+	if typ := p.Classify(19, 1, 20); typ != CoreAlignment {
+		t.Errorf("19 of 20 strains: got %s, want core\n", typ)
+	}
+	if typ := p.Classify(18, 1, 20); typ != DispAlignment {
+		t.Errorf("18 of 20 strains: got %s, want disp\n", typ)
+	}
+	p.SingleCopy = true
+	if typ := p.Classify(20, 2, 20); typ != DispAlignment {
+		t.Errorf("multi-copy with single copy core: got %s, want disp\n", typ)
+	}
+	p.Paralogs = true
+	if typ := p.Classify(20, 2, 20); typ != ParalogAlignment {
+		t.Errorf("multi-copy with separated paralogs: got %s, want paralog\n", typ)
+	}
+	if !reflect.DeepEqual(p.Types(), []string{"core", "disp", "paralog", "pan"}) {
+		t.Errorf("types: got %v\n", p.Types())
+	}
+}
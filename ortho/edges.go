@@ -0,0 +1,143 @@
+package ortho
+
+// Weighting and normalization of edges between genes, as OrthoMCL does.
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EdgeParams are parameters of edges clustered by MCL.
+type EdgeParams struct {
+	Weight     string // weight of hits, "bitscore" or "identity".
+	Normalize  bool   // divide weights by the mean weight of each genome pair.
+	InParalogs bool   // add edges between in-paralogs of each genome.
+}
+
+// DefaultEdgeParams weights edges by bit scores, normalized per genome pair.
+var DefaultEdgeParams = EdgeParams{Weight: "bitscore", Normalize: true}
+
+// Validate checks the weight of edges.
+func (p EdgeParams) Validate() error {
+	switch p.Weight {
+	case "", "bitscore", "identity":
+		return nil
+	}
+	return fmt.Errorf("unknown edge weight: %s, want bitscore or identity", p.Weight)
+}
+
+// hitWeight returns the weight of a hit,
+// whose weight name has been validated by EdgeParams.Validate.
+// Missing bit scores, such as "*" of usearch, fall back to the identity,
+// and missing identities to 1, so that hits are still linked.
+func hitWeight(h Hit, weight string) float64 {
+	switch weight {
+	case "", "bitscore":
+		if validWeight(h.BitScore) {
+			return h.BitScore
+		}
+		return hitWeight(h, "identity")
+	case "identity":
+		if validWeight(h.PIdent) {
+			return h.PIdent
+		}
+		return 1
+	}
+	panic(fmt.Sprintf("unknown edge weight: %s", weight))
+}
+
+// validWeight checks if a weight is finite and positive.
+func validWeight(w float64) bool {
+	return !math.IsNaN(w) && !math.IsInf(w, 0) && w > 0
+}
+
+// bestWeights updates the best weight of hits of each query,
+// whose names are appended by the genome accession.
+func bestWeights(best map[string]float64, hits []Hit, acc, weight string) {
+	for _, h := range hits {
+		q := h.QSeqid + "|" + acc
+		if w := hitWeight(h, weight); w > best[q] {
+			best[q] = w
+		}
+	}
+}
+
+// inParalogPairs returns pairs of genes of a genome, from hits of the genome
+// against itself, which hit each other at least as well as
+// their best hits in other genomes.
+// Genes are named by their ids appended by the genome accession,
+// and scored by the mean weight of the reciprocal hits.
+func inParalogPairs(hits []Hit, best map[string]float64, acc, weight string) []Pair {
+	m := make(map[string]map[string]float64)
+	for _, h := range hits {
+		if h.QSeqid == h.SSeqid {
+			continue
+		}
+		if m[h.QSeqid] == nil {
+			m[h.QSeqid] = make(map[string]float64)
+		}
+		if w := hitWeight(h, weight); w > m[h.QSeqid][h.SSeqid] {
+			m[h.QSeqid][h.SSeqid] = w
+		}
+	}
+
+	pairs := []Pair{}
+	for q, targets := range m {
+		for s, w1 := range targets {
+			if q > s {
+				continue
+			}
+			w2, found := m[s][q]
+			if !found {
+				continue
+			}
+			a, b := q+"|"+acc, s+"|"+acc
+			if w1 >= best[a] && w2 >= best[b] {
+				pairs = append(pairs, Pair{A: a, B: b, Score: (w1 + w2) / 2})
+			}
+		}
+	}
+	return pairs
+}
+
+// normalizeEdges divides scores of pairs by the mean score of pairs
+// between the same genomes, or within the same genome for in-paralogs,
+// so that closely related genomes do not dominate the clustering.
+// Pairs without finite positive scores are left out of means, and kept as they are.
+func normalizeEdges(pairs []Pair) {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, p := range pairs {
+		if !validWeight(p.Score) {
+			continue
+		}
+		key := genomePairKey(p)
+		sums[key] += p.Score
+		counts[key]++
+	}
+	for i, p := range pairs {
+		if !validWeight(p.Score) {
+			continue
+		}
+		key := genomePairKey(p)
+		mean := sums[key] / float64(counts[key])
+		if mean > 0 {
+			pairs[i].Score = p.Score / mean
+		}
+	}
+}
+
+// genomePairKey returns the accessions of genomes of a pair, in order.
+func genomePairKey(p Pair) string {
+	a, b := genomeAcc(p.A), genomeAcc(p.B)
+	if a > b {
+		a, b = b, a
+	}
+	return a + "\t" + b
+}
+
+// genomeAcc returns the genome accession appended to a gene name.
+func genomeAcc(name string) string {
+	return name[strings.LastIndex(name, "|")+1:]
+}
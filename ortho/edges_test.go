@@ -0,0 +1,91 @@
+package ortho
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInParalogPairs(t *testing.T) {
+	hits := []Hit{
+		{QSeqid: "g1", SSeqid: "g1", BitScore: 500},
+		{QSeqid: "g1", SSeqid: "g2", BitScore: 300},
+		{QSeqid: "g2", SSeqid: "g1", BitScore: 310},
+		{QSeqid: "g1", SSeqid: "g3", BitScore: 100},
+		{QSeqid: "g3", SSeqid: "g1", BitScore: 100},
+	}
+	// g3 hits other genomes better than g1.
+	best := map[string]float64{"g1|A": 200, "g2|A": 250, "g3|A": 150}
+	pairs := inParalogPairs(hits, best, "A", "bitscore")
+	if len(pairs) != 1 {
+		t.Fatalf("in-paralogs: got %v, want one pair\n", pairs)
+	}
+	p := pairs[0]
+	if p.A != "g1|A" || p.B != "g2|A" || p.Score != 305 {
+		t.Errorf("in-paralogs: got %v, want {g1|A g2|A 305}\n", p)
+	}
+}
+
+func TestNormalizeEdges(t *testing.T) {
+	pairs := []Pair{
+		{A: "a1|A", B: "b1|B", Score: 100},
+		{A: "b2|B", B: "a2|A", Score: 300},
+		{A: "a1|A", B: "c1|C", Score: 10},
+		{A: "a1|A", B: "a2|A", Score: 50},
+	}
+	normalizeEdges(pairs)
+	expected := []float64{0.5, 1.5, 1, 1}
+	for i, p := range pairs {
+		if math.Abs(p.Score-expected[i]) > 1e-12 {
+			t.Errorf("pair %d: got %g, want %g\n", i, p.Score, expected[i])
+		}
+	}
+}
+
+func TestInParalogPairsMissingScores(t *testing.T) {
+	// usearch reports "*" bit scores, parsed as NaN.
+	nan := math.NaN()
+	hits := []Hit{
+		{QSeqid: "g1", SSeqid: "g2", PIdent: 90, BitScore: nan},
+		{QSeqid: "g2", SSeqid: "g1", PIdent: 92, BitScore: nan},
+		{QSeqid: "g1", SSeqid: "g3", PIdent: nan, BitScore: nan},
+		{QSeqid: "g3", SSeqid: "g1", PIdent: 0, BitScore: 0},
+	}
+	pairs := inParalogPairs(hits, map[string]float64{}, "A", "bitscore")
+	expected := map[string]float64{"g2|A": 91, "g3|A": 1}
+	if len(pairs) != len(expected) {
+		t.Fatalf("in-paralogs: got %v, want %d pairs\n", pairs, len(expected))
+	}
+	for _, p := range pairs {
+		if p.A != "g1|A" || p.Score != expected[p.B] {
+			t.Errorf("in-paralogs: got %v, want score %g\n", p, expected[p.B])
+		}
+	}
+}
+
+func TestNormalizeEdgesMissingScores(t *testing.T) {
+	pairs := []Pair{
+		{A: "a1|A", B: "b1|B", Score: 100},
+		{A: "a2|A", B: "b2|B", Score: math.NaN()},
+		{A: "a3|A", B: "b3|B", Score: 300},
+	}
+	normalizeEdges(pairs)
+	if pairs[0].Score != 0.5 || !math.IsNaN(pairs[1].Score) || pairs[2].Score != 1.5 {
+		t.Errorf("normalized: got %v, want scores 0.5, NaN and 1.5\n", pairs)
+	}
+}
+
+func TestEdgeParamsValidate(t *testing.T) {
+	for _, weight := range []string{"", "bitscore", "identity"} {
+		if err := (EdgeParams{Weight: weight}).Validate(); err != nil {
+			t.Errorf("weight %q: got %v\n", weight, err)
+		}
+	}
+	if err := (EdgeParams{Weight: "evalue"}).Validate(); err == nil {
+		t.Errorf("weight evalue: got no error\n")
+	}
+	opts := DefaultOptions()
+	opts.Edges.Weight = "evalue"
+	if _, err := AllAgainstAll(nil, "", opts); err == nil {
+		t.Errorf("AllAgainstAll with weight evalue: got no error\n")
+	}
+}
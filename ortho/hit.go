@@ -1,14 +1,19 @@
 package ortho
 
-// Functions for parsing usearch results.
+// Functions for parsing search results.
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-// Container for blast 6 out result.
+// Container for blast 6 out result,
+// with query and subject lengths of backends reporting them.
 type Hit struct {
 	QSeqid   string
 	SSeqid   string
@@ -26,47 +31,252 @@ type Hit struct {
 	BitScore float64
 }
 
-// Parse and return a Hit struct.
-func parseHit(fields []string) Hit {
-	h := Hit{}
-	h.QSeqid = strings.Split(fields[0], "|")[1]
-	h.SSeqid = strings.Split(fields[1], "|")[1]
-	h.PIdent = atof(fields[2])
-	h.Length = atoi(fields[3])
-	h.Mismatch = atoi(fields[4])
-	h.GapOpen = atoi(fields[5])
-	h.QStart = atoi(fields[6])
-	h.QEnd = atoi(fields[7])
-	h.SStart = atoi(fields[8])
-	h.SEnd = atoi(fields[9])
-	h.EValue = atof(fields[10])
-	h.BitScore = atof(fields[11])
+// QueryCoverage returns the fraction of the query covered by the hit,
+// or NaN without the query length.
+func (h Hit) QueryCoverage() float64 {
+	return coverage(h.QStart, h.QEnd, h.QLen)
+}
 
-	return h
+// SubjectCoverage returns the fraction of the subject covered by the hit,
+// or NaN without the subject length.
+func (h Hit) SubjectCoverage() float64 {
+	return coverage(h.SStart, h.SEnd, h.SLen)
 }
 
-// String to float64 helper.
-func atof(s string) float64 {
-	if s == "*" {
+func coverage(start, end, length int) float64 {
+	if length <= 0 {
 		return math.NaN()
 	}
+	if end < start {
+		start, end = end, start
+	}
+	return float64(end-start+1) / float64(length)
+}
+
+// BlastColumns are the default columns of blast tabular output,
+// followed by the optional query and subject lengths.
+var BlastColumns = []string{"qseqid", "sseqid", "pident", "length", "mismatch", "gapopen",
+	"qstart", "qend", "sstart", "send", "evalue", "bitscore", "qlen", "slen"}
+
+// optionalColumns may be missing at the end of rows.
+var optionalColumns = map[string]bool{"qlen": true, "slen": true}
+
+// blastFieldNames maps names in the "# Fields:" line of outfmt 7
+// to column names.
+var blastFieldNames = map[string]string{
+	"query id":           "qseqid",
+	"query acc.":         "qseqid",
+	"query acc.ver":      "qseqid",
+	"subject id":         "sseqid",
+	"subject acc.":       "sseqid",
+	"subject acc.ver":    "sseqid",
+	"% identity":         "pident",
+	"alignment length":   "length",
+	"mismatches":         "mismatch",
+	"gap opens":          "gapopen",
+	"q. start":           "qstart",
+	"q. end":             "qend",
+	"s. start":           "sstart",
+	"s. end":             "send",
+	"evalue":             "evalue",
+	"bit score":          "bitscore",
+	"query length":       "qlen",
+	"subject length":     "slen",
+	"% query coverage":   "qcovs",
+	"subject tax ids":    "staxids",
+	"subject title":      "stitle",
+	"query/sbjct frames": "frames",
+}
+
+// IDFunc extracts the sequence id from the label of a query or a subject.
+type IDFunc func(label string) string
+
+// FullID keeps labels as ids.
+func FullID(label string) string {
+	return label
+}
+
+// FieldID returns an IDFunc taking the i-th field of labels separated by sep,
+// or the label itself if it has fewer fields.
+func FieldID(sep string, i int) IDFunc {
+	return func(label string) string {
+		terms := strings.Split(label, sep)
+		if len(terms) > i {
+			return terms[i]
+		}
+		return label
+	}
+}
+
+// RegexpID returns an IDFunc taking the first submatch of the regular expression,
+// or the whole match without submatches, or the label itself without matches.
+func RegexpID(re *regexp.Regexp) IDFunc {
+	return func(label string) string {
+		m := re.FindStringSubmatch(label)
+		switch {
+		case m == nil:
+			return label
+		case len(m) > 1:
+			return m[1]
+		}
+		return m[0]
+	}
+}
+
+// Parse the sequence id, such as 16127995 in gi|16127995|ref|NP_414542.1|,
+// or return the label itself if it has no fields.
+func parseSeqid(label string) string {
+	return FieldID("|", 1)(label)
+}
+
+// HitReader reads hits of blast tabular output, outfmt 6 or 7,
+// one row at a time.
+// Comment lines are skipped, and the "# Fields:" line of outfmt 7
+// sets the columns.
+type HitReader struct {
+	Columns []string // names of columns, BlastColumns by default; unknown ones are skipped.
+	ID      IDFunc   // extracts ids of queries and subjects, parseSeqid by default.
+
+	scanner *bufio.Scanner
+	line    int
+}
 
-	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
-	if err != nil {
-		panic(err)
+// NewHitReader returns a HitReader with the default columns and id extraction.
+func NewHitReader(r io.Reader) *HitReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &HitReader{
+		Columns: BlastColumns,
+		ID:      parseSeqid,
+		scanner: scanner,
 	}
-	return f
 }
 
-// String to int helper.
-func atoi(s string) int {
+// Read returns the next hit, or io.EOF at the end of the input.
+func (r *HitReader) Read() (Hit, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimRight(r.scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			r.parseComment(line)
+			continue
+		}
+		h, err := r.parse(strings.Split(line, "\t"))
+		if err != nil {
+			return h, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return h, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Hit{}, err
+	}
+	return Hit{}, io.EOF
+}
+
+// ReadAll returns all the remaining hits.
+func (r *HitReader) ReadAll() ([]Hit, error) {
+	hits := []Hit{}
+	for {
+		h, err := r.Read()
+		if err == io.EOF {
+			return hits, nil
+		}
+		if err != nil {
+			return hits, err
+		}
+		hits = append(hits, h)
+	}
+}
+
+// parseComment sets columns by the "# Fields:" line of outfmt 7.
+func (r *HitReader) parseComment(line string) {
+	const prefix = "# Fields:"
+	if !strings.HasPrefix(line, prefix) {
+		return
+	}
+	columns := []string{}
+	for _, name := range strings.Split(line[len(prefix):], ",") {
+		name = strings.TrimSpace(name)
+		if column, found := blastFieldNames[name]; found {
+			name = column
+		}
+		columns = append(columns, name)
+	}
+	r.Columns = columns
+}
+
+// parse returns the hit of fields of a row.
+func (r *HitReader) parse(fields []string) (h Hit, err error) {
+	if len(fields) > len(r.Columns) {
+		return h, fmt.Errorf("%d fields, more than %d columns", len(fields), len(r.Columns))
+	}
+	for _, column := range r.Columns[len(fields):] {
+		if !optionalColumns[column] {
+			return h, fmt.Errorf("%d fields, missing column %s", len(fields), column)
+		}
+	}
+
+	id := r.ID
+	if id == nil {
+		id = parseSeqid
+	}
+	for i, field := range fields {
+		column := r.Columns[i]
+		switch column {
+		case "qseqid":
+			h.QSeqid = id(field)
+		case "sseqid":
+			h.SSeqid = id(field)
+		case "pident":
+			h.PIdent, err = atof(field)
+		case "length":
+			h.Length, err = atoi(field)
+		case "mismatch":
+			h.Mismatch, err = atoi(field)
+		case "gapopen":
+			h.GapOpen, err = atoi(field)
+		case "qstart":
+			h.QStart, err = atoi(field)
+		case "qend":
+			h.QEnd, err = atoi(field)
+		case "sstart":
+			h.SStart, err = atoi(field)
+		case "send":
+			h.SEnd, err = atoi(field)
+		case "evalue":
+			h.EValue, err = atof(field)
+		case "bitscore":
+			h.BitScore, err = atof(field)
+		case "qlen":
+			h.QLen, err = atoi(field)
+		case "slen":
+			h.SLen, err = atoi(field)
+		}
+		if err != nil {
+			return h, fmt.Errorf("column %s: %v", column, err)
+		}
+	}
+
+	return h, nil
+}
+
+// String to float64 helper, with NaN for missing values.
+func atof(s string) (float64, error) {
+	s = strings.TrimSpace(s)
 	if s == "*" {
-		return 0
+		return math.NaN(), nil
 	}
+	return strconv.ParseFloat(s, 64)
+}
 
-	i, err := strconv.Atoi(strings.TrimSpace(s))
-	if err != nil {
-		panic(err)
+// String to int helper, with 0 for missing values.
+func atoi(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "*" {
+		return 0, nil
 	}
-	return i
+	return strconv.Atoi(s)
 }
@@ -0,0 +1,61 @@
+package ortho
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHitReaderOutfmt7(t *testing.T) {
+	input := `# BLASTP 2.12.0+
+# Query: gi|1|ref|NP_1|
+# Fields: query acc.ver, subject acc.ver, % identity, alignment length, mismatches, gap opens, q. start, q. end, s. start, s. end, evalue, bit score, query length, subject length
+# 1 hits found
+gi|1|ref|NP_1|	gi|2|ref|NP_2|	98.5	200	3	0	1	200	11	210	1e-100	380	200	400
+`
+	hits, err := NewHitReader(strings.NewReader(input)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("hits: got %d, want 1\n", len(hits))
+	}
+	h := hits[0]
+	if h.QSeqid != "1" || h.SSeqid != "2" || h.BitScore != 380 || h.SLen != 400 {
+		t.Errorf("hit: got %+v\n", h)
+	}
+	if h.QueryCoverage() != 1 || h.SubjectCoverage() != 0.5 {
+		t.Errorf("coverages: got %g and %g, want 1 and 0.5\n", h.QueryCoverage(), h.SubjectCoverage())
+	}
+}
+
+func TestHitReaderOutfmt6(t *testing.T) {
+	// rows of 12 columns, without lengths.
+	input := "lcl_a1\tlcl_b1\t90.0\t100\t10\t0\t1\t100\t1\t100\t*\t150\n"
+	r := NewHitReader(strings.NewReader(input))
+	r.ID = RegexpID(regexp.MustCompile(`lcl_(\w+)`))
+	h, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.QSeqid != "a1" || h.SSeqid != "b1" || !math.IsNaN(h.EValue) {
+		t.Errorf("hit: got %+v\n", h)
+	}
+	if !math.IsNaN(h.QueryCoverage()) {
+		t.Errorf("query coverage without length: got %g, want NaN\n", h.QueryCoverage())
+	}
+}
+
+func TestHitReaderErrors(t *testing.T) {
+	inputs := []string{
+		"a\tb\t90.0\n",
+		"a\tb\tx\t100\t10\t0\t1\t100\t1\t100\t1e-5\t150\n",
+		"a\tb\t90\t100\t10\t0\t1\t100\t1\t100\t1e-5\t150\t100\t100\t1\n",
+	}
+	for _, input := range inputs {
+		if _, err := NewHitReader(strings.NewReader(input)).ReadAll(); err == nil {
+			t.Errorf("no error for %q\n", input)
+		}
+	}
+}
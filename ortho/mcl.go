@@ -0,0 +1,305 @@
+package ortho
+
+// Markov clustering (MCL) of weighted pairs, in pure Go.
+
+import (
+	"math"
+	"sort"
+)
+
+// MCLParams are parameters of Markov clustering.
+type MCLParams struct {
+	Inflation      float64 // inflation exponent, larger for finer clusters.
+	PruneThreshold float64 // entries below it are pruned after inflation.
+	MaxEntries     int     // max entries kept in each column, 0 for no limit.
+	MaxIter        int     // max number of iterations.
+	Tolerance      float64 // convergence threshold of the max change of entries.
+}
+
+// DefaultMCLParams follows the defaults of the mcl program,
+// and is used by MCL.
+var DefaultMCLParams = MCLParams{
+	Inflation:      2.0,
+	PruneThreshold: 1e-4,
+	MaxEntries:     1000,
+	MaxIter:        100,
+	Tolerance:      1e-6,
+}
+
+// MCL clusters pairs with DefaultMCLParams.
+func MCL(pairs []Pair) (clusters [][]string) {
+	return MCLWithParams(pairs, DefaultMCLParams)
+}
+
+// MCLWithParams clusters nodes of pairs by Markov clustering,
+// in which edges are weighted by scores of pairs, or 1 without scores.
+// Clusters are ordered by size, from the largest, and then by their first members;
+// members of a cluster are sorted by name.
+func MCLWithParams(pairs []Pair, p MCLParams) (clusters [][]string) {
+	names, m := adjacency(pairs)
+	n := len(names)
+	if n == 0 {
+		return
+	}
+	for j := range m {
+		m[j].normalize()
+	}
+
+	for iter := 0; iter < p.MaxIter; iter++ {
+		next := expand(m, n)
+		for j := range next {
+			next[j].inflate(p.Inflation)
+			next[j].prune(p.PruneThreshold, p.MaxEntries)
+		}
+		change := maxChange(m, next, n)
+		m = next
+		if change < p.Tolerance {
+			break
+		}
+	}
+
+	return interpret(m, names)
+}
+
+// sparseCol is a sparse column of a matrix, sorted by row indices.
+type sparseCol struct {
+	rows   []int
+	values []float64
+}
+
+func (c *sparseCol) sum() (s float64) {
+	for _, v := range c.values {
+		s += v
+	}
+	return
+}
+
+func (c *sparseCol) normalize() {
+	s := c.sum()
+	if s == 0 {
+		return
+	}
+	for i := range c.values {
+		c.values[i] /= s
+	}
+}
+
+func (c *sparseCol) inflate(r float64) {
+	for i, v := range c.values {
+		c.values[i] = math.Pow(v, r)
+	}
+	c.normalize()
+}
+
+// prune removes entries below the threshold,
+// keeps at most maxEntries largest ones,
+// and normalizes the column.
+func (c *sparseCol) prune(threshold float64, maxEntries int) {
+	entries := []entry{}
+	for i, v := range c.values {
+		if v >= threshold {
+			entries = append(entries, entry{c.rows[i], v})
+		}
+	}
+	// always keep the largest entry.
+	if len(entries) == 0 && len(c.values) > 0 {
+		k := 0
+		for i, v := range c.values {
+			if v > c.values[k] {
+				k = i
+			}
+		}
+		entries = append(entries, entry{c.rows[k], c.values[k]})
+	}
+	if maxEntries > 0 && len(entries) > maxEntries {
+		sort.Stable(byValue(entries))
+		entries = entries[:maxEntries]
+		sort.Sort(byRow(entries))
+	}
+
+	c.rows, c.values = c.rows[:0], c.values[:0]
+	for _, e := range entries {
+		c.rows = append(c.rows, e.row)
+		c.values = append(c.values, e.value)
+	}
+	c.normalize()
+}
+
+// adjacency returns sorted node names, and the weighted adjacency matrix,
+// with self loops of the max weight of each column, as mcl does.
+func adjacency(pairs []Pair) (names []string, m []sparseCol) {
+	index := make(map[string]int)
+	for _, pair := range pairs {
+		for _, name := range []string{pair.A, pair.B} {
+			if _, found := index[name]; !found {
+				index[name] = 0
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		index[name] = i
+	}
+
+	weights := make([]map[int]float64, len(names))
+	for j := range weights {
+		weights[j] = make(map[int]float64)
+	}
+	for _, pair := range pairs {
+		a, b := index[pair.A], index[pair.B]
+		if a == b {
+			continue
+		}
+		w := pair.Score
+		if w <= 0 {
+			w = 1
+		}
+		// keep the larger weight of duplicated pairs.
+		if w > weights[b][a] {
+			weights[b][a] = w
+			weights[a][b] = w
+		}
+	}
+
+	m = make([]sparseCol, len(names))
+	for j, col := range weights {
+		max := 1.0
+		if len(col) > 0 {
+			max = 0
+			for _, w := range col {
+				max = math.Max(max, w)
+			}
+		}
+		col[j] = max
+		for i := range col {
+			m[j].rows = append(m[j].rows, i)
+		}
+		sort.Ints(m[j].rows)
+		for _, i := range m[j].rows {
+			m[j].values = append(m[j].values, col[i])
+		}
+	}
+	return
+}
+
+// expand returns the square of the matrix.
+func expand(m []sparseCol, n int) []sparseCol {
+	next := make([]sparseCol, n)
+	dense := make([]float64, n)
+	touched := make([]bool, n)
+	for j := range m {
+		rows := []int{}
+		for k, kRow := range m[j].rows {
+			v := m[j].values[k]
+			col := m[kRow]
+			for i, row := range col.rows {
+				if !touched[row] {
+					touched[row] = true
+					rows = append(rows, row)
+				}
+				dense[row] += col.values[i] * v
+			}
+		}
+		sort.Ints(rows)
+		for _, row := range rows {
+			next[j].rows = append(next[j].rows, row)
+			next[j].values = append(next[j].values, dense[row])
+			dense[row] = 0
+			touched[row] = false
+		}
+	}
+	return next
+}
+
+// maxChange returns the max absolute difference of entries of two matrices.
+func maxChange(a, b []sparseCol, n int) (change float64) {
+	dense := make([]float64, n)
+	for j := range a {
+		for i, row := range a[j].rows {
+			dense[row] = a[j].values[i]
+		}
+		for i, row := range b[j].rows {
+			change = math.Max(change, math.Abs(dense[row]-b[j].values[i]))
+			dense[row] = 0
+		}
+		for _, row := range a[j].rows {
+			change = math.Max(change, dense[row])
+			dense[row] = 0
+		}
+	}
+	return
+}
+
+// interpret returns clusters of the converged matrix.
+// Each node joins the attractors it flows to,
+// and overlapping clusters are merged.
+func interpret(m []sparseCol, names []string) (clusters [][]string) {
+	parent := make([]int, len(names))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for j := range m {
+		for _, row := range m[j].rows {
+			a, b := find(row), find(j)
+			if a != b {
+				parent[a] = b
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	roots := []int{}
+	for i, name := range names {
+		r := find(i)
+		if _, found := groups[r]; !found {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], name)
+	}
+	for _, r := range roots {
+		clusters = append(clusters, groups[r])
+	}
+	sort.Sort(bySize(clusters))
+	return
+}
+
+// entry of a sparse column.
+type entry struct {
+	row   int
+	value float64
+}
+
+// byValue sorts entries by values, from the largest.
+type byValue []entry
+
+func (e byValue) Len() int           { return len(e) }
+func (e byValue) Less(i, j int) bool { return e[i].value > e[j].value }
+func (e byValue) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// byRow sorts entries by row indices.
+type byRow []entry
+
+func (e byRow) Len() int           { return len(e) }
+func (e byRow) Less(i, j int) bool { return e[i].row < e[j].row }
+func (e byRow) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// bySize sorts clusters by sizes, from the largest, and then by first members.
+type bySize [][]string
+
+func (c bySize) Len() int { return len(c) }
+func (c bySize) Less(i, j int) bool {
+	if len(c[i]) != len(c[j]) {
+		return len(c[i]) > len(c[j])
+	}
+	return c[i][0] < c[j][0]
+}
+func (c bySize) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
@@ -0,0 +1,58 @@
+package ortho
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// clique returns pairs connecting all nodes.
+func clique(nodes []string, score float64) (pairs []Pair) {
+	for i := 0; i < len(nodes); i++ {
+		for j := i + 1; j < len(nodes); j++ {
+			pairs = append(pairs, Pair{A: nodes[i], B: nodes[j], Score: score})
+		}
+	}
+	return
+}
+
+func TestMCL(t *testing.T) {
+	a := []string{"a1", "a2", "a3", "a4", "a5"}
+	b := []string{"b1", "b2", "b3", "b4"}
+	pairs := append(clique(a, 1), clique(b, 1)...)
+	// a weak link between the cliques.
+	pairs = append(pairs, Pair{A: "a1", B: "b1", Score: 0.1})
+	// a separated pair.
+	pairs = append(pairs, Pair{A: "c2", B: "c1", Score: 1})
+
+	clusters := MCL(pairs)
+	expected := [][]string{a, b, {"c1", "c2"}}
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("clusters: got %v, want %v\n", clusters, expected)
+	}
+
+	// the order of pairs does not matter.
+	reversed := []Pair{}
+	for i := len(pairs) - 1; i >= 0; i-- {
+		reversed = append(reversed, pairs[i])
+	}
+	if !reflect.DeepEqual(MCL(reversed), clusters) {
+		t.Errorf("clusters depend on the order of pairs\n")
+	}
+}
+
+func TestMCLInflation(t *testing.T) {
+	// a chain of nodes is split by a large inflation.
+	pairs := []Pair{}
+	for i := 0; i < 11; i++ {
+		pairs = append(pairs, Pair{A: fmt.Sprintf("n%02d", i), B: fmt.Sprintf("n%02d", i+1), Score: 1})
+	}
+	p := DefaultMCLParams
+	p.Inflation = 1.2
+	coarse := MCLWithParams(pairs, p)
+	p.Inflation = 4
+	fine := MCLWithParams(pairs, p)
+	if len(fine) <= len(coarse) {
+		t.Errorf("number of clusters: got %d with inflation 4, %d with 1.2\n", len(fine), len(coarse))
+	}
+}
@@ -3,84 +3,128 @@ package ortho
 // Perform ortholog clustering using reciprocal best hit and MCL.
 
 import (
-	"bufio"
-	"bytes"
-	"fmt"
 	"github.com/mingzhi/meta/strain"
-	"io"
-	"io/ioutil"
-	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 )
 
+// Options of ortholog clustering.
+type Options struct {
+	Backend SearchBackend // search between genomes.
+	// ParalogBackend searches genomes against themselves for in-paralogs,
+	// and should report more than the top hit, which is the query itself.
+	ParalogBackend SearchBackend
+	Edges          EdgeParams
+	MCL            MCLParams
+}
+
+// DefaultOptions searches with usearch, using DefaultSearchOptions
+// between genomes and up to 10 targets within genomes,
+// and clusters edges of DefaultEdgeParams with DefaultMCLParams.
+func DefaultOptions() Options {
+	paralogOptions := DefaultSearchOptions
+	paralogOptions.MaxTargets = 10
+	return Options{
+		Backend:        Usearch{DefaultSearchOptions},
+		ParalogBackend: Usearch{paralogOptions},
+		Edges:          DefaultEdgeParams,
+		MCL:            DefaultMCLParams,
+	}
+}
+
 // OrthoMCL identifies ortholog groups for a group of closed related strains.
-// First, it uses usearch to find the best reciprocal top hits
+// First, it uses the search backend of options to find the best reciprocal top hits
 // for each pair of genomes.
 // Then it uses MCL to obtain ortholog clusters.
 //
 // strains: a array of strains.
 // dir: reference genome folder, containing their genome sequences.
-func OrthoMCl(strains []strain.Strain, dir string) (clusters [][]string) {
+// opts: search backends, edge and MCL parameters.
+//
+// It returns an error if a genome has no protein sequences,
+// or if a search fails.
+func OrthoMCl(strains []strain.Strain, dir string, opts Options) (clusters [][]string, err error) {
 	// Check and prepare blast database.
 	// Remove those strains that do not have protein sequences.
 	selectStrains := []strain.Strain{}
 	for _, s := range strains {
 		for _, g := range s.Genomes {
 			f := filepath.Join(dir, s.Path, g.RefAcc()+".faa")
-			if _, err := os.Stat(f); err == nil {
-				// index the sequence if have not.
-				if !IsUsearchDBExist(f) {
-					UsearchMakeUDB(f)
+			if _, err = os.Stat(f); err != nil {
+				return
+			}
+			// index the sequence if have not.
+			if err = opts.Backend.MakeDB(f); err != nil {
+				return
+			}
+			if opts.Edges.InParalogs {
+				if err = opts.ParalogBackend.MakeDB(f); err != nil {
+					return
 				}
-				selectStrains = append(selectStrains, s)
-			} else {
-				panic(err)
 			}
+			selectStrains = append(selectStrains, s)
 		}
 	}
 
 	// Perform all against all usearch.
-	pairs := AllAgainstAll(selectStrains, dir)
+	pairs, err := AllAgainstAll(selectStrains, dir, opts)
+	if err != nil {
+		return
+	}
 
 	// Get ortholog clusters using MCL
-	clusters = MCL(pairs)
+	clusters = MCLWithParams(pairs, opts.MCL)
 
 	return
 }
 
+// Pair of genes, with the score of the edge between them.
 type Pair struct {
 	A, B  string
 	Score float64
 }
 
-// AllAgainstAll perform all against all usearch
-// for the reciprocal top hits for each pair of genomes.
-func AllAgainstAll(strains []strain.Strain, dir string) []Pair {
+// AllAgainstAll perform all against all search
+// for the reciprocal top hits for each pair of genomes,
+// and for in-paralogs of each genome if opts.Edges.InParalogs is set.
+// Pairs are scored by the mean weight of reciprocal hits,
+// normalized per genome pair if opts.Edges.Normalize is set.
+// It returns the first error of searches.
+func AllAgainstAll(strains []strain.Strain, dir string, opts Options) ([]Pair, error) {
+	params := opts.Edges
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 	ncpu := runtime.GOMAXPROCS(0)
-	// Prepare jobs.
+	// Prepare jobs, until quit is closed on the first error.
+	quit := make(chan struct{})
 	jobs := make(chan []strain.Strain, ncpu)
 	go func() {
+		defer close(jobs)
 		for i := 0; i < len(strains); i++ {
 			a := strains[i]
 			for j := i + 1; j < len(strains); j++ {
 				b := strains[j]
 				pair := []strain.Strain{a, b}
-				jobs <- pair
-
+				select {
+				case jobs <- pair:
+				case <-quit:
+					return
+				}
 			}
 		}
-		close(jobs)
 	}()
 
-	done := make(chan bool)    // signal channel.
-	results := make(chan Pair) // result channel.
+	done := make(chan bool)            // signal channel.
+	results := make(chan searchResult) // result channel.
 	for i := 0; i < ncpu; i++ {
 		go func() {
 			for pair := range jobs {
+				if isClosed(quit) {
+					continue
+				}
 				a := pair[0]
 				b := pair[1]
 				for _, genomeA := range a.Genomes {
@@ -89,14 +133,19 @@ func AllAgainstAll(strains []strain.Strain, dir string) []Pair {
 						accB := genomeB.RefAcc()
 						fileNameA := filepath.Join(dir, a.Path, accA+".faa")
 						fileNameB := filepath.Join(dir, b.Path, accB+".faa")
-						pairs := ReciprocalBestHits(fileNameA, fileNameB)
+						pairs, hitsA, hitsB, err := reciprocalBestHits(opts.Backend, fileNameA, fileNameB, params.Weight)
+						res := searchResult{best: make(map[string]float64), err: err}
 						for _, p := range pairs {
 							newP := Pair{
-								A: p.A + "|" + accA,
-								B: p.B + "|" + accB,
+								A:     p.A + "|" + accA,
+								B:     p.B + "|" + accB,
+								Score: p.Score,
 							}
-							results <- newP
+							res.pairs = append(res.pairs, newP)
 						}
+						bestWeights(res.best, hitsA, accA, params.Weight)
+						bestWeights(res.best, hitsB, accB, params.Weight)
+						results <- res
 					}
 				}
 			}
@@ -111,90 +160,167 @@ func AllAgainstAll(strains []strain.Strain, dir string) []Pair {
 		close(results)
 	}()
 
+	// Keep receiving results after an error, so that workers finish,
+	// which skip the remaining jobs.
 	allPairs := []Pair{}
-
-	for pair := range results {
-		allPairs = append(allPairs, pair)
+	best := make(map[string]float64)
+	var err error
+	for res := range results {
+		if res.err != nil {
+			if err == nil {
+				err = res.err
+				close(quit)
+			}
+			continue
+		}
+		allPairs = append(allPairs, res.pairs...)
+		for q, w := range res.best {
+			if w > best[q] {
+				best[q] = w
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return allPairs
-}
-
-// Find reciprocal top hits for a pair of genomes.
-func ReciprocalBestHits(a, b string) []Pair {
-	hits1 := UsearchGlobal(a, b)
-	m1 := hit2Map(hits1)
-	hits2 := UsearchGlobal(b, a)
-	m2 := hit2Map(hits2)
-	pairs := []Pair{}
-	for q, s := range m1 {
-		s2, found := m2[s]
-		if found && q == s2 {
-			pairs = append(pairs, Pair{A: q, B: s})
+	if params.InParalogs {
+		paralogs, err := findInParalogs(strains, dir, best, opts.ParalogBackend, params.Weight)
+		if err != nil {
+			return nil, err
 		}
+		allPairs = append(allPairs, paralogs...)
+	}
+
+	if params.Normalize {
+		normalizeEdges(allPairs)
 	}
 
-	return pairs
+	return allPairs, nil
 }
 
-func hit2Map(hits []Hit) map[string]string {
-	m := make(map[string]string)
-	for _, h := range hits {
-		_, found := m[h.QSeqid]
-		if !found {
-			m[h.QSeqid] = h.SSeqid
+// searchResult holds reciprocal best hits of a pair of genomes,
+// and the best hit weight of each gene, or the error of searches.
+type searchResult struct {
+	pairs []Pair
+	best  map[string]float64
+	err   error
+}
+
+// findInParalogs searches each genome against itself using the backend,
+// and returns in-paralog pairs, which hit each other
+// at least as well as their best hits in other genomes.
+func findInParalogs(strains []strain.Strain, dir string, best map[string]float64, backend SearchBackend, weight string) ([]Pair, error) {
+	ncpu := runtime.GOMAXPROCS(0)
+	// Prepare jobs, until quit is closed on the first error.
+	quit := make(chan struct{})
+	jobs := make(chan string, ncpu)
+	go func() {
+		defer close(jobs)
+		for _, s := range strains {
+			for _, g := range s.Genomes {
+				select {
+				case jobs <- filepath.Join(dir, s.Path, g.RefAcc()+".faa"):
+				case <-quit:
+					return
+				}
+			}
 		}
+	}()
+
+	done := make(chan bool)
+	results := make(chan searchResult)
+	for i := 0; i < ncpu; i++ {
+		go func() {
+			for fileName := range jobs {
+				if isClosed(quit) {
+					continue
+				}
+				acc := strings.TrimSuffix(filepath.Base(fileName), ".faa")
+				hits, err := backend.Search(fileName, fileName)
+				if err != nil {
+					results <- searchResult{err: err}
+					continue
+				}
+				results <- searchResult{pairs: inParalogPairs(hits, best, acc, weight)}
+			}
+			done <- true
+		}()
 	}
 
-	return m
-}
+	go func() {
+		for i := 0; i < ncpu; i++ {
+			<-done
+		}
+		close(results)
+	}()
 
-func MCL(pairs []Pair) (clusters [][]string) {
-	content := new(bytes.Buffer)
-	for _, p := range pairs {
-		content.WriteString(fmt.Sprintf("%s %s 1\n", p.A, p.B))
+	pairs := []Pair{}
+	var err error
+	for res := range results {
+		if res.err != nil && err == nil {
+			err = res.err
+			close(quit)
+		}
+		pairs = append(pairs, res.pairs...)
 	}
-
-	f, err := ioutil.TempFile("", "mcl")
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
-	f.Close()
-	defer os.Remove(f.Name())
-
-	cmd := exec.Command("mcl", "-", "--abc", "-o", f.Name())
-	cmd.Stdin = content
+	return pairs, nil
+}
 
-	stderr := new(bytes.Buffer)
-	cmd.Stderr = stderr
+// Find reciprocal top hits for a pair of genomes,
+// searched by the backend of options,
+// and scored by the mean edge weight of the two hits.
+func ReciprocalBestHits(a, b string, opts Options) ([]Pair, error) {
+	pairs, _, _, err := reciprocalBestHits(opts.Backend, a, b, opts.Edges.Weight)
+	return pairs, err
+}
 
-	if err := cmd.Run(); err != nil {
-		panic(string(stderr.Bytes()))
+// reciprocalBestHits returns reciprocal top hits,
+// and hits of the two searches.
+func reciprocalBestHits(backend SearchBackend, a, b, weight string) (pairs []Pair, hits1, hits2 []Hit, err error) {
+	if hits1, err = backend.Search(a, b); err != nil {
+		return
+	}
+	m1 := hit2Map(hits1)
+	if hits2, err = backend.Search(b, a); err != nil {
+		return
+	}
+	m2 := hit2Map(hits2)
+	pairs = []Pair{}
+	for q, h1 := range m1 {
+		h2, found := m2[h1.SSeqid]
+		if found && q == h2.SSeqid {
+			score := (hitWeight(h1, weight) + hitWeight(h2, weight)) / 2
+			pairs = append(pairs, Pair{A: q, B: h1.SSeqid, Score: score})
+		}
 	}
 
-	clusters = readMCL(f.Name())
 	return
 }
 
-func readMCL(filename string) (clusters [][]string) {
-	f, err := os.Open(filename)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-	r := bufio.NewReader(f)
-	for {
-		l, err := r.ReadString('\n')
-		if err != nil {
-			if err != io.EOF {
-				panic(err)
-			}
-			break
+// Map each query to its best hit, with the highest bit score,
+// or identity without bit scores, or the first one among equals.
+func hit2Map(hits []Hit) map[string]Hit {
+	m := make(map[string]Hit)
+	for _, h := range hits {
+		best, found := m[h.QSeqid]
+		if !found || hitWeight(h, "bitscore") > hitWeight(best, "bitscore") {
+			m[h.QSeqid] = h
 		}
-		l = strings.TrimSpace(l)
-		genes := strings.Split(l, "\t")
-		clusters = append(clusters, genes)
 	}
 
-	return
+	return m
+}
+
+// isClosed checks if a quit channel has been closed.
+func isClosed(quit <-chan struct{}) bool {
+	select {
+	case <-quit:
+		return true
+	default:
+		return false
+	}
 }
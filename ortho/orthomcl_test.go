@@ -0,0 +1,64 @@
+package ortho
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/strain"
+	"sync/atomic"
+	"testing"
+)
+
+// errBackend fails every search, and counts searches if count is set.
+type errBackend struct {
+	count *int32
+}
+
+func (b errBackend) MakeDB(db string) error { return nil }
+func (b errBackend) Search(q, db string) ([]Hit, error) {
+	if b.count != nil {
+		atomic.AddInt32(b.count, 1)
+	}
+	return nil, errors.New("search failed")
+}
+
+func TestAllAgainstAllError(t *testing.T) {
+	strains := []strain.Strain{}
+	for _, acc := range []string{"NC_000001", "NC_000002", "NC_000003"} {
+		g := genome.Genome{Accession: acc}
+		strains = append(strains, strain.Strain{Path: acc, Genomes: []genome.Genome{g}})
+	}
+	opts := DefaultOptions()
+	opts.Backend = errBackend{}
+	pairs, err := AllAgainstAll(strains, "", opts)
+	if err == nil || pairs != nil {
+		t.Errorf("failed searches: got %v and error %v\n", pairs, err)
+	}
+
+	count := 0
+	opts.Backend = countBackend{count: &count}
+	opts.ParalogBackend = errBackend{}
+	opts.Edges.InParalogs = true
+	if _, err := AllAgainstAll(strains, "", opts); err == nil {
+		t.Errorf("failed in-paralog searches: got no error\n")
+	}
+}
+
+func TestAllAgainstAllStopsOnError(t *testing.T) {
+	strains := []strain.Strain{}
+	for i := 0; i < 50; i++ {
+		acc := fmt.Sprintf("NC_%06d", i)
+		g := genome.Genome{Accession: acc}
+		strains = append(strains, strain.Strain{Path: acc, Genomes: []genome.Genome{g}})
+	}
+	var count int32
+	opts := DefaultOptions()
+	opts.Backend = errBackend{count: &count}
+	if _, err := AllAgainstAll(strains, "", opts); err == nil {
+		t.Fatal("failed searches: got no error")
+	}
+	// workers skip the remaining pairs after the first error.
+	if n := len(strains) * (len(strains) - 1) / 2; int(count) >= n {
+		t.Errorf("searches after the first error: got %d of %d pairs\n", count, n)
+	}
+}
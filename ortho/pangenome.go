@@ -0,0 +1,245 @@
+package ortho
+
+// Gene presence and absence of ortholog clusters across strains,
+// and pangenome summaries, as Roary reports.
+
+import (
+	"bufio"
+	"github.com/mingzhi/meta/strain"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Classes of ortholog clusters by the fraction of strains having them.
+const (
+	Core     = "core"
+	SoftCore = "soft_core"
+	Shell    = "shell"
+	Cloud    = "cloud"
+)
+
+// PangenomeParams are min fractions of strains having
+// core, soft-core and shell clusters; the others are cloud clusters.
+type PangenomeParams struct {
+	Core     float64
+	SoftCore float64
+	Shell    float64
+}
+
+// DefaultPangenomeParams follows the defaults of Roary.
+var DefaultPangenomeParams = PangenomeParams{Core: 0.99, SoftCore: 0.95, Shell: 0.15}
+
+// Classify returns the class of a cluster present in n of total strains.
+func (p PangenomeParams) Classify(n, total int) string {
+	f := float64(n) / float64(total)
+	switch {
+	case f >= p.Core:
+		return Core
+	case f >= p.SoftCore:
+		return SoftCore
+	case f >= p.Shell:
+		return Shell
+	}
+	return Cloud
+}
+
+// PresenceAbsence returns genes of each cluster in each strain,
+// in the order of clusters and strains.
+// Cluster members are gene ids appended by genome accessions,
+// as returned by OrthoMCl.
+func PresenceAbsence(clusters [][]string, strains []strain.Strain) [][][]string {
+	index := make(map[string]int)
+	for i, s := range strains {
+		for _, g := range s.Genomes {
+			index[g.RefAcc()] = i
+		}
+	}
+
+	matrix := make([][][]string, len(clusters))
+	for i, cluster := range clusters {
+		matrix[i] = make([][]string, len(strains))
+		for _, name := range cluster {
+			j, found := index[genomeAcc(name)]
+			if found {
+				matrix[i][j] = append(matrix[i][j], name)
+			}
+		}
+	}
+	return matrix
+}
+
+// AddSingletons returns clusters followed by singleton clusters
+// of genes without edges, which are in the protein sequences (.faa)
+// of genomes of strains in dir, but not in any cluster.
+// Genomes without protein sequences are skipped.
+func AddSingletons(clusters [][]string, strains []strain.Strain, dir string) ([][]string, error) {
+	clustered := make(map[string]bool)
+	for _, cluster := range clusters {
+		for _, name := range cluster {
+			clustered[name] = true
+		}
+	}
+
+	all := append([][]string{}, clusters...)
+	for _, s := range strains {
+		for _, g := range s.Genomes {
+			fileName := filepath.Join(dir, s.Path, g.RefAcc()+".faa")
+			if _, err := os.Stat(fileName); os.IsNotExist(err) {
+				continue
+			}
+			ids, err := readSeqids(fileName)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				name := id + "|" + g.RefAcc()
+				if !clustered[name] {
+					clustered[name] = true
+					all = append(all, []string{name})
+				}
+			}
+		}
+	}
+	return all, nil
+}
+
+// readSeqids returns ids of sequences in a fasta file,
+// parsed from labels as search hits are.
+func readSeqids(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ids := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, ">") {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) > 0 {
+			ids = append(ids, parseSeqid(fields[0]))
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// StrainCount returns the number of strains having the cluster.
+func StrainCount(row [][]string) (n int) {
+	for _, genes := range row {
+		if len(genes) > 0 {
+			n++
+		}
+	}
+	return
+}
+
+// PangenomeSummary counts clusters of each class.
+type PangenomeSummary struct {
+	Strains  int
+	Clusters int
+	Core     int
+	SoftCore int
+	Shell    int
+	Cloud    int
+}
+
+// Summarize counts clusters of the presence-absence matrix by classes.
+func Summarize(matrix [][][]string, numStrains int, p PangenomeParams) PangenomeSummary {
+	s := PangenomeSummary{Strains: numStrains, Clusters: len(matrix)}
+	for _, row := range matrix {
+		switch p.Classify(StrainCount(row), numStrains) {
+		case Core:
+			s.Core++
+		case SoftCore:
+			s.SoftCore++
+		case Shell:
+			s.Shell++
+		default:
+			s.Cloud++
+		}
+	}
+	return s
+}
+
+// AccumulationPoint is the mean and standard deviation
+// of pangenome and core genome sizes of a number of strains.
+type AccumulationPoint struct {
+	Strains  int
+	PanMean  float64
+	PanSD    float64
+	CoreMean float64
+	CoreSD   float64
+}
+
+// Accumulation returns pangenome and core genome accumulation curves,
+// averaged over random orders of strains.
+// The pangenome has clusters present in any of the first strains,
+// and the core genome has clusters present in all of them.
+func Accumulation(matrix [][][]string, numStrains, permutations int, rng *rand.Rand) []AccumulationPoint {
+	pan := make([][]float64, numStrains)
+	core := make([][]float64, numStrains)
+	for k := 0; k < permutations; k++ {
+		order := rng.Perm(numStrains)
+		for _, row := range matrix {
+			// positions of the first presence and the first absence.
+			firstPresent, firstAbsent := numStrains, numStrains
+			for i, j := range order {
+				if len(row[j]) > 0 {
+					if i < firstPresent {
+						firstPresent = i
+					}
+				} else if i < firstAbsent {
+					firstAbsent = i
+				}
+			}
+			for i := 0; i < numStrains; i++ {
+				if pan[i] == nil {
+					pan[i] = make([]float64, permutations)
+					core[i] = make([]float64, permutations)
+				}
+				if i >= firstPresent {
+					pan[i][k]++
+				}
+				if i < firstAbsent {
+					core[i][k]++
+				}
+			}
+		}
+	}
+
+	points := []AccumulationPoint{}
+	for i := 0; i < numStrains; i++ {
+		p := AccumulationPoint{Strains: i + 1}
+		if pan[i] != nil {
+			p.PanMean, p.PanSD = meanSD(pan[i])
+			p.CoreMean, p.CoreSD = meanSD(core[i])
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// meanSD returns the mean and the standard deviation.
+func meanSD(values []float64) (mean, sd float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	if len(values) > 1 {
+		sd = math.Sqrt(sd / float64(len(values)-1))
+	} else {
+		sd = 0
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package ortho
+
+import (
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/strain"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPangenome(t *testing.T) {
+	strains := []strain.Strain{}
+	for _, acc := range []string{"NC_000001", "NC_000002", "NC_000003", "NC_000004"} {
+		g := genome.Genome{Accession: acc}
+		strains = append(strains, strain.Strain{Path: acc, Genomes: []genome.Genome{g}})
+	}
+	clusters := [][]string{
+		{"a1|NC_000001", "a2|NC_000002", "a3|NC_000003", "a4|NC_000004"},
+		{"b1|NC_000001", "b2|NC_000002", "b3|NC_000003"},
+		{"c1|NC_000001", "c2|NC_000001"},
+	}
+	matrix := PresenceAbsence(clusters, strains)
+	if len(matrix[2][0]) != 2 || StrainCount(matrix[2]) != 1 {
+		t.Errorf("presence of in-paralogs: got %v\n", matrix[2])
+	}
+
+	p := PangenomeParams{Core: 0.99, SoftCore: 0.7, Shell: 0.3}
+	s := Summarize(matrix, len(strains), p)
+	expected := PangenomeSummary{Strains: 4, Clusters: 3, Core: 1, SoftCore: 1, Cloud: 1}
+	if s != expected {
+		t.Errorf("summary: got %+v, want %+v\n", s, expected)
+	}
+
+	points := Accumulation(matrix, len(strains), 50, rand.New(rand.NewSource(1)))
+	last := points[len(points)-1]
+	if last.PanMean != 3 || last.CoreMean != 1 || last.PanSD != 0 {
+		t.Errorf("accumulation of all strains: got %+v\n", last)
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].PanMean < points[i-1].PanMean || points[i].CoreMean > points[i-1].CoreMean {
+			t.Errorf("accumulation curves are not monotone: %+v\n", points)
+		}
+	}
+}
+
+func TestAddSingletons(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ortho_pangenome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	strains := []strain.Strain{}
+	faa := map[string]string{
+		"NC_000001": ">gi|1|ref|YP_1.1| a\nMKV\n>gi|2|ref|YP_2.1| b\nMKI\n",
+		"NC_000002": ">gi|3|ref|YP_3.1| a\nMKV\n",
+	}
+	for _, acc := range []string{"NC_000001", "NC_000002", "NC_000003"} {
+		g := genome.Genome{Accession: acc}
+		strains = append(strains, strain.Strain{Path: acc, Genomes: []genome.Genome{g}})
+		// the third strain has no protein sequences.
+		if seqs, found := faa[acc]; found {
+			os.MkdirAll(filepath.Join(dir, acc), 0755)
+			ioutil.WriteFile(filepath.Join(dir, acc, acc+".faa"), []byte(seqs), 0644)
+		}
+	}
+
+	clusters := [][]string{{"1|NC_000001", "3|NC_000002"}}
+	all, err := AddSingletons(clusters, strains, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"1|NC_000001", "3|NC_000002"}, {"2|NC_000001"}}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("clusters: got %v, want %v\n", all, expected)
+	}
+	matrix := PresenceAbsence(all, strains)
+	if StrainCount(matrix[1]) != 1 || len(matrix[1][0]) != 1 {
+		t.Errorf("presence of singletons: got %v\n", matrix[1])
+	}
+}
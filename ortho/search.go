@@ -0,0 +1,130 @@
+package ortho
+
+// Homology search backends for finding hits between genomes.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+)
+
+// SearchBackend searches protein sequences in a query file
+// against a sequence db file.
+type SearchBackend interface {
+	// MakeDB indexes the sequence db file, if it has not been indexed.
+	MakeDB(db string) error
+	// Search returns hits of queries passing the thresholds.
+	Search(q, db string) ([]Hit, error)
+}
+
+// SearchOptions are thresholds and settings of homology search.
+type SearchOptions struct {
+	Identity   float64 // min fraction of identical positions, 0-1.
+	Coverage   float64 // min fraction of the query covered by the alignment, 0-1.
+	SCoverage  float64 // min fraction of the subject covered by the alignment, 0-1.
+	EValue     float64 // max e-value, 0 for the default of the backend.
+	MaxTargets int     // max number of hits per query.
+	Threads    int     // number of threads of each search.
+}
+
+// DefaultSearchOptions follows the former usearch settings,
+// -id 0.8 -top_hit_only.
+var DefaultSearchOptions = SearchOptions{Identity: 0.8, MaxTargets: 1, Threads: 1}
+
+// NewSearchBackend returns a search backend by name:
+// usearch, blastp, diamond or mmseqs.
+func NewSearchBackend(name string, opts SearchOptions) (SearchBackend, error) {
+	if opts.MaxTargets <= 0 {
+		opts.MaxTargets = 1
+	}
+	if opts.Threads <= 0 {
+		opts.Threads = 1
+	}
+	switch name {
+	case "", "usearch":
+		return Usearch{opts}, nil
+	case "blastp":
+		return Blastp{opts}, nil
+	case "diamond":
+		return Diamond{opts}, nil
+	case "mmseqs":
+		return MMseqs{opts}, nil
+	}
+	return nil, fmt.Errorf("unknown search backend: %s", name)
+}
+
+// runSearch runs a search command, which writes tabular hits to the output file
+// passed by the args function, and returns hits passing the thresholds.
+func runSearch(name string, args func(out string) []string, opts SearchOptions) ([]Hit, error) {
+	// Prepare temp file for output.
+	temp, err := ioutil.TempFile(os.TempDir(), name)
+	if err != nil {
+		return nil, err
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	if err := run(exec.Command(name, args(temp.Name())...)); err != nil {
+		return nil, err
+	}
+
+	hits, err := readHits(temp.Name(), parseSeqid)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s hits: %v", name, err)
+	}
+	return filterHits(hits, opts)
+}
+
+// readHits reads hits in blast tabular format,
+// extracting ids of queries and subjects by the id function.
+func readHits(fileName string, id IDFunc) ([]Hit, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := NewHitReader(f)
+	r.ID = id
+	hits, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	return hits, nil
+}
+
+// filterHits keeps hits passing identity, coverage and e-value thresholds,
+// and at most MaxTargets hits of each query, in the order of the output.
+// It returns an error if a coverage threshold is set,
+// but hits have no query or subject lengths to check it.
+func filterHits(hits []Hit, opts SearchOptions) ([]Hit, error) {
+	counts := make(map[string]int)
+	filtered := []Hit{}
+	for _, h := range hits {
+		if h.PIdent/100 < opts.Identity {
+			continue
+		}
+		qcov, scov := h.QueryCoverage(), h.SubjectCoverage()
+		if opts.Coverage > 0 && math.IsNaN(qcov) {
+			return nil, fmt.Errorf("coverage threshold %g, but hit %s %s has no query length", opts.Coverage, h.QSeqid, h.SSeqid)
+		}
+		if opts.SCoverage > 0 && math.IsNaN(scov) {
+			return nil, fmt.Errorf("subject coverage threshold %g, but hit %s %s has no subject length", opts.SCoverage, h.QSeqid, h.SSeqid)
+		}
+		if qcov < opts.Coverage || scov < opts.SCoverage {
+			continue
+		}
+		if opts.EValue > 0 && h.EValue > opts.EValue {
+			continue
+		}
+		if opts.MaxTargets > 0 && counts[h.QSeqid] >= opts.MaxTargets {
+			continue
+		}
+		counts[h.QSeqid]++
+		filtered = append(filtered, h)
+	}
+	return filtered, nil
+}
@@ -0,0 +1,88 @@
+package ortho
+
+import (
+	"strings"
+	"testing"
+)
+
+// value of a flag in arguments, or "" if it is missing.
+func argValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestBackendArgs(t *testing.T) {
+	opts := SearchOptions{Identity: 0.8, Coverage: 0.5, SCoverage: 0.6, EValue: 1e-5, MaxTargets: 1, Threads: 4}
+	cases := []struct {
+		name     string
+		args     []string
+		expected map[string]string
+	}{
+		{"blastp", Blastp{opts}.args("q.faa", "db.faa", "out"), map[string]string{
+			"-query": "q.faa", "-db": "db.faa", "-out": "out",
+			"-outfmt":          "6 " + strings.Join(BlastColumns, " "),
+			"-max_target_seqs": "5", "-num_threads": "4", "-evalue": "1e-05"}},
+		{"diamond", Diamond{opts}.args("q.faa", "db.faa", "out"), map[string]string{
+			"--query": "q.faa", "--db": "db.faa.dmnd", "--out": "out", "--outfmt": "6",
+			"--id": "80", "--query-cover": "50", "--subject-cover": "60",
+			"--max-target-seqs": "1", "--threads": "4", "--evalue": "1e-05"}},
+		{"mmseqs", MMseqs{opts}.args("q.faa", "db.faa", "out", "tmp"), map[string]string{
+			"easy-search": "q.faa", "--format-output": strings.Join(mmseqsFields, ","),
+			"--min-seq-id": "0.8", "-c": "0.5", "--cov-mode": "2",
+			"--max-accept": "1", "--threads": "4", "-e": "1e-05"}},
+		{"usearch", Usearch{opts}.args("q.faa", "db.faa", "out"), map[string]string{
+			"-usearch_global": "q.faa", "-db": "db.faa", "-blast6out": "out",
+			"-id": "0.8", "-threads": "4", "-query_cov": "0.5", "-target_cov": "0.6"}},
+	}
+	for _, c := range cases {
+		for flag, v := range c.expected {
+			if got := argValue(c.args, flag); got != v {
+				t.Errorf("%s %s: got %q, want %q\n", c.name, flag, got, v)
+			}
+		}
+	}
+
+	// blastp reports all columns, and mmseqs the same fields in its names.
+	if len(mmseqsFields) != len(BlastColumns) {
+		t.Errorf("mmseqs fields: got %d, want %d\n", len(mmseqsFields), len(BlastColumns))
+	}
+	args := MMseqs{opts}.args("q.faa", "db.faa", "out", "tmp")
+	if strings.Join(args[:5], " ") != "easy-search q.faa db.faa out tmp" {
+		t.Errorf("mmseqs positional arguments: got %v\n", args[:5])
+	}
+	// without an e-value, backends use their defaults.
+	opts.EValue = 0
+	if argValue(Blastp{opts}.args("q", "db", "out"), "-evalue") != "" {
+		t.Errorf("blastp: got -evalue without a threshold\n")
+	}
+}
+
+func TestFilterHitsCoverage(t *testing.T) {
+	hits := []Hit{
+		{QSeqid: "a1", SSeqid: "b1", PIdent: 90, QStart: 1, QEnd: 50, QLen: 100, SStart: 1, SEnd: 50, SLen: 60},
+		{QSeqid: "a2", SSeqid: "b2", PIdent: 90, QStart: 1, QEnd: 90, QLen: 100, SStart: 1, SEnd: 90, SLen: 100},
+	}
+	filtered, err := filterHits(hits, SearchOptions{Identity: 0.8, Coverage: 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0].QSeqid != "a2" {
+		t.Errorf("filtered by coverage: got %v\n", filtered)
+	}
+
+	// hits without lengths, such as those of usearch.
+	hits = []Hit{{QSeqid: "a1", SSeqid: "b1", PIdent: 90, QStart: 1, QEnd: 50, SStart: 1, SEnd: 50}}
+	if _, err := filterHits(hits, SearchOptions{Coverage: 0.5}); err == nil {
+		t.Errorf("coverage without query lengths: got no error\n")
+	}
+	if _, err := filterHits(hits, SearchOptions{SCoverage: 0.5}); err == nil {
+		t.Errorf("subject coverage without subject lengths: got no error\n")
+	}
+	if filtered, err := filterHits(hits, SearchOptions{}); err != nil || len(filtered) != 1 {
+		t.Errorf("no coverage thresholds: got %v, %v\n", filtered, err)
+	}
+}
@@ -2,71 +2,79 @@ package ortho
 
 import (
 	"bytes"
-	"encoding/csv"
-	"io/ioutil"
-	"log"
+	"fmt"
 	"os"
 	"os/exec"
 )
 
-// Cmd running usearch -makeudb_usearch,
-// which indexs sequence db, similar to makeblastdb in BLAST.
-func UsearchMakeUDB(f string) {
-	cmd := exec.Command("usearch", "-makeudb_usearch", f, "-output", f+".udb")
-	run(cmd)
-
-	return
+// Usearch searches with usearch -usearch_global.
+type Usearch struct {
+	Options SearchOptions
 }
 
-// Cmd running usearch -usearch-global
-// to find the top hit.
-func UsearchGlobal(q, db string) []Hit {
-	// Prepare temp file for output.
-	temp, err := ioutil.TempFile(os.TempDir(), "usearchglobal")
-	if err != nil {
-		log.Panic(err)
+// MakeDB indexes the sequence db with usearch -makeudb_usearch.
+func (u Usearch) MakeDB(db string) error {
+	if IsUsearchDBExist(db) {
+		return nil
 	}
-	temp.Close()
-	defer os.Remove(temp.Name())
+	return UsearchMakeUDB(db)
+}
 
-	// Prepare command and run it.
-	cmd := exec.Command("usearch", "-usearch_global", q, "-db", db, "-id", "0.8", "-blast6out", temp.Name(), "-top_hit_only")
-	run(cmd)
+// Search runs usearch -usearch_global.
+// usearch does not report sequence lengths and e-values of global hits,
+// so coverage thresholds are passed as -query_cov and -target_cov,
+// and not checked again.
+func (u Usearch) Search(q, db string) ([]Hit, error) {
+	opts := u.Options
+	opts.Coverage, opts.SCoverage = 0, 0
+	return runSearch("usearch", func(out string) []string {
+		return u.args(q, db, out)
+	}, opts)
+}
 
-	// Open and read results.
-	f, err := os.Open(temp.Name())
-	if err != nil {
-		log.Panic(err)
+// args returns arguments of usearch -usearch_global writing hits to the out file.
+func (u Usearch) args(q, db, out string) []string {
+	opts := u.Options
+	args := []string{"-usearch_global", q, "-db", db,
+		"-id", fmt.Sprintf("%g", opts.Identity), "-blast6out", out,
+		"-threads", fmt.Sprintf("%d", opts.Threads)}
+	if opts.MaxTargets == 1 {
+		args = append(args, "-top_hit_only")
+	} else {
+		args = append(args, "-maxaccepts", fmt.Sprintf("%d", opts.MaxTargets))
 	}
-	defer f.Close()
-
-	// CSV reader.
-	csvRd := csv.NewReader(f)
-	csvRd.Comma = '\t'
-	rows, err := csvRd.ReadAll()
-	if err != nil {
-		log.Panic(err)
+	if opts.Coverage > 0 {
+		args = append(args, "-query_cov", fmt.Sprintf("%g", opts.Coverage))
 	}
-
-	// Parse hits.
-	hits := []Hit{}
-	for _, fields := range rows {
-		h := parseHit(fields)
-		hits = append(hits, h)
+	if opts.SCoverage > 0 {
+		args = append(args, "-target_cov", fmt.Sprintf("%g", opts.SCoverage))
 	}
+	return args
+}
 
-	return hits
+// Cmd running usearch -makeudb_usearch,
+// which indexs sequence db, similar to makeblastdb in BLAST.
+func UsearchMakeUDB(f string) error {
+	cmd := exec.Command("usearch", "-makeudb_usearch", f, "-output", f+".udb")
+	return run(cmd)
+}
+
+// Cmd running usearch -usearch-global
+// to find the top hit.
+func UsearchGlobal(q, db string) ([]Hit, error) {
+	return Usearch{DefaultSearchOptions}.Search(q, db)
 }
 
-// A helper to run command.
-func run(cmd *exec.Cmd) {
+// A helper to run command,
+// returning an error with its stderr if it fails.
+func run(cmd *exec.Cmd) error {
 	stderr := new(bytes.Buffer)
 	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
-		log.Panicf("Error when %s: %s\n", cmd.Args[1], stderr.String())
+		return fmt.Errorf("%s %s: %v: %s", cmd.Args[0], cmd.Args[1], err, stderr.String())
 	}
 
-	return
+	return nil
 }
 
 // Check if the sequence db is already indexed.
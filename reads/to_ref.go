@@ -6,6 +6,7 @@ import (
 )
 
 // Obtain the sequence of a read mapping to the reference genome.
+// Hard-clipped bases are not in the read sequence, and are skipped.
 // Return the mapped sequence.
 func Map2Ref(r *sam.Record) []byte {
 	s := []byte{}
@@ -16,7 +17,7 @@ func Map2Ref(r *sam.Record) []byte {
 		case sam.CigarMatch, sam.CigarMismatch, sam.CigarEqual:
 			s = append(s, read[p:p+c.Len()]...)
 			p += c.Len()
-		case sam.CigarInsertion, sam.CigarSoftClipped, sam.CigarHardClipped:
+		case sam.CigarInsertion, sam.CigarSoftClipped:
 			p += c.Len()
 		case sam.CigarDeletion, sam.CigarSkipped:
 			s = append(s, bytes.Repeat([]byte{'*'}, c.Len())...)
@@ -40,3 +41,44 @@ func MapMated2Ref(r PairedEndRead) []byte {
 
 	return s1
 }
+
+// Obtain the sequence and base qualities of a read mapping to the reference genome.
+// Deleted positions have a quality of zero.
+func Map2RefQual(r *sam.Record) (s, q []byte) {
+	p := 0                 // position in the read sequence.
+	read := r.Seq.Expand() // read sequence.
+	qual := r.Qual         // base qualities.
+	for _, c := range r.Cigar {
+		switch c.Type() {
+		case sam.CigarMatch, sam.CigarMismatch, sam.CigarEqual:
+			s = append(s, read[p:p+c.Len()]...)
+			q = append(q, qual[p:p+c.Len()]...)
+			p += c.Len()
+		case sam.CigarInsertion, sam.CigarSoftClipped:
+			p += c.Len()
+		case sam.CigarDeletion, sam.CigarSkipped:
+			s = append(s, bytes.Repeat([]byte{'*'}, c.Len())...)
+			q = append(q, make([]byte, c.Len())...)
+		}
+	}
+
+	return
+}
+
+// Obtain the sequence and base qualities of a pair of mated reads to the reference genome.
+func MapMated2RefQual(r PairedEndRead) (s, q []byte) {
+	s1, q1 := Map2RefQual(r.ReadLeft)
+	s2, q2 := Map2RefQual(r.ReadRight)
+	space := r.ReadRight.Pos - (r.ReadLeft.Pos + len(s1))
+	if space > 0 {
+		s1 = append(s1, bytes.Repeat([]byte{'*'}, space)...)
+		q1 = append(q1, make([]byte, space)...)
+		s1 = append(s1, s2...)
+		q1 = append(q1, q2...)
+	} else {
+		s1 = append(s1, s2[-space:]...)
+		q1 = append(q1, q2[-space:]...)
+	}
+
+	return s1, q1
+}
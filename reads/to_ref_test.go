@@ -0,0 +1,37 @@
+package reads
+
+import (
+	"github.com/biogo/hts/sam"
+	"testing"
+)
+
+func TestMap2RefClipped(t *testing.T) {
+	r := &sam.Record{
+		Seq: sam.NewSeq([]byte("TTACGTAGG")),
+		Cigar: sam.Cigar{
+			sam.NewCigarOp(sam.CigarHardClipped, 5),
+			sam.NewCigarOp(sam.CigarSoftClipped, 2),
+			sam.NewCigarOp(sam.CigarMatch, 3),
+			sam.NewCigarOp(sam.CigarDeletion, 1),
+			sam.NewCigarOp(sam.CigarInsertion, 1),
+			sam.NewCigarOp(sam.CigarMatch, 3),
+			sam.NewCigarOp(sam.CigarHardClipped, 4),
+		},
+		Qual: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}
+
+	expected := "ACG*AGG"
+	if s := string(Map2Ref(r)); s != expected {
+		t.Errorf("Map2Ref: expect %s, got %s\n", expected, s)
+	}
+	s, q := Map2RefQual(r)
+	if string(s) != expected {
+		t.Errorf("Map2RefQual: expect %s, got %s\n", expected, s)
+	}
+	expectedQual := []byte{3, 4, 5, 0, 7, 8, 9}
+	for i := range expectedQual {
+		if q[i] != expectedQual[i] {
+			t.Errorf("%d, expect quality %d, got %d\n", i, expectedQual[i], q[i])
+		}
+	}
+}
@@ -0,0 +1,349 @@
+// Package sim simulates bacterial population samples
+// under a coalescent with gene conversion,
+// for validating the estimators of cov and fit.
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Params of the coalescent with gene conversion.
+// Time is scaled so that each pair of lineages coalesces at rate 1.
+type Params struct {
+	SampleSize  int     // number of genomes.
+	Length      int     // genome length in bp.
+	Theta       float64 // population mutation rate per site, 2Nu.
+	Rho         float64 // population rate of initiating gene conversion per site, 2Nr.
+	TractLength float64 // mean length of transferred fragments (geometric).
+}
+
+// sampleSet is a bit set of sample indices.
+type sampleSet []uint64
+
+func newSampleSet(n int) sampleSet {
+	return make(sampleSet, (n+63)/64)
+}
+
+func (s sampleSet) add(i int) {
+	s[i/64] |= 1 << uint(i%64)
+}
+
+func (s sampleSet) has(i int) bool {
+	return s[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (s sampleSet) union(t sampleSet) sampleSet {
+	u := make(sampleSet, len(s))
+	for i := range s {
+		u[i] = s[i] | t[i]
+	}
+	return u
+}
+
+func (s sampleSet) equal(t sampleSet) bool {
+	for i := range s {
+		if s[i] != t[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s sampleSet) count() (n int) {
+	for _, w := range s {
+		for ; w != 0; w &= w - 1 {
+			n++
+		}
+	}
+	return
+}
+
+// segment is a stretch [from, to) of ancestral material,
+// ancestral to the samples.
+type segment struct {
+	from, to int
+	samples  sampleSet
+}
+
+// lineage carries sorted, non-overlapping segments.
+type lineage []segment
+
+func (l lineage) material() (m int) {
+	for _, s := range l {
+		m += s.to - s.from
+	}
+	return
+}
+
+// span is the length from the first to the last site of the material.
+func (l lineage) span() int {
+	return l[len(l)-1].to - l[0].from
+}
+
+// mutation on a branch, inherited by the samples at the site.
+type mutation struct {
+	site    int
+	time    float64
+	samples sampleSet
+}
+
+// Simulate returns sequences of a sample,
+// generated by the coalescent with gene conversion,
+// and Jukes-Cantor mutations on a random ancestral sequence.
+func Simulate(p Params, rng *rand.Rand) [][]byte {
+	n, L := p.SampleSize, p.Length
+	lineages := []lineage{}
+	for i := 0; i < n; i++ {
+		s := newSampleSet(n)
+		s.add(i)
+		lineages = append(lineages, lineage{{from: 0, to: L, samples: s}})
+	}
+
+	mutations := []mutation{}
+	t := 0.0
+	for len(lineages) > 1 {
+		k := float64(len(lineages))
+		coalRate := k * (k - 1) / 2
+		// only tracts overlapping the material span of a lineage split it.
+		convRates := make([]float64, len(lineages))
+		convRate := 0.0
+		for i, l := range lineages {
+			convRates[i] = p.Rho / 2 * (float64(l.span()) + math.Max(p.TractLength-1, 0))
+			convRate += convRates[i]
+		}
+		total := coalRate + convRate
+		dt := rng.ExpFloat64() / total
+
+		for _, l := range lineages {
+			mutations = mutate(mutations, l, t, dt, p.Theta/2, rng)
+		}
+		t += dt
+
+		if rng.Float64()*total < coalRate {
+			i := rng.Intn(len(lineages))
+			j := rng.Intn(len(lineages) - 1)
+			if j >= i {
+				j++
+			}
+			merged := merge(lineages[i], lineages[j], n)
+			lineages = removeLineages(lineages, i, j)
+			if len(merged) > 0 {
+				lineages = append(lineages, merged)
+			}
+		} else {
+			i := pick(convRates, convRate, rng)
+			from, to := tract(lineages[i], p.TractLength, L, rng)
+			inside, outside := split(lineages[i], from, to)
+			if len(inside) > 0 && len(outside) > 0 {
+				lineages[i] = outside
+				lineages = append(lineages, inside)
+			}
+		}
+	}
+
+	return mutateSequences(mutations, n, L, rng)
+}
+
+// mutate adds mutations on the lineage during time dt, at rate mu per site.
+func mutate(mutations []mutation, l lineage, t, dt, mu float64, rng *rand.Rand) []mutation {
+	rate := mu * dt
+	if rate <= 0 {
+		return mutations
+	}
+	m := float64(l.material())
+	for x := rng.ExpFloat64() / rate; x < m; x += rng.ExpFloat64() / rate {
+		offset := int(x)
+		for _, s := range l {
+			if offset < s.to-s.from {
+				mut := mutation{site: s.from + offset, time: t + rng.Float64()*dt, samples: s.samples}
+				mutations = append(mutations, mut)
+				break
+			}
+			offset -= s.to - s.from
+		}
+	}
+	return mutations
+}
+
+// pick returns an index with probability proportional to its rate.
+func pick(rates []float64, total float64, rng *rand.Rand) int {
+	x := rng.Float64() * total
+	for i, r := range rates {
+		if x < r {
+			return i
+		}
+		x -= r
+	}
+	return len(rates) - 1
+}
+
+// tract returns a gene conversion tract [from, to) overlapping the span of the lineage.
+// Tracts start in the span at rate 1 per site,
+// or k sites left of it at rate (1-1/mean)^k, reaching it by memorylessness.
+func tract(l lineage, mean float64, L int, rng *rand.Rand) (from, to int) {
+	first, span := l[0].from, l.span()
+	left := math.Max(mean-1, 0)
+	if rng.Float64()*(float64(span)+left) < float64(span) {
+		from = first + rng.Intn(span)
+		to = from + tractLength(mean, rng)
+	} else {
+		from = first - tractLength(mean, rng)
+		to = first + tractLength(mean, rng)
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > L {
+		to = L
+	}
+	return
+}
+
+// tractLength returns a geometric length of mean.
+func tractLength(mean float64, rng *rand.Rand) int {
+	if mean <= 1 {
+		return 1
+	}
+	return 1 + int(math.Log(1-rng.Float64())/math.Log(1-1/mean))
+}
+
+// merge coalesces two lineages,
+// dropping segments that reached the most recent common ancestor of all samples.
+func merge(a, b lineage, n int) (merged lineage) {
+	bounds := []int{}
+	for _, l := range []lineage{a, b} {
+		for _, s := range l {
+			bounds = append(bounds, s.from, s.to)
+		}
+	}
+	sort.Ints(bounds)
+
+	for i := 0; i+1 < len(bounds); i++ {
+		from, to := bounds[i], bounds[i+1]
+		if from == to {
+			continue
+		}
+		sa, sb := covering(a, from), covering(b, from)
+		var samples sampleSet
+		switch {
+		case sa != nil && sb != nil:
+			samples = sa.union(sb)
+		case sa != nil:
+			samples = sa
+		case sb != nil:
+			samples = sb
+		default:
+			continue
+		}
+		if samples.count() == n {
+			continue
+		}
+
+		last := len(merged) - 1
+		if last >= 0 && merged[last].to == from && merged[last].samples.equal(samples) {
+			merged[last].to = to
+		} else {
+			merged = append(merged, segment{from: from, to: to, samples: samples})
+		}
+	}
+	return
+}
+
+// covering returns samples of the segment covering the site, or nil.
+func covering(l lineage, site int) sampleSet {
+	i := sort.Search(len(l), func(i int) bool { return l[i].to > site })
+	if i < len(l) && l[i].from <= site {
+		return l[i].samples
+	}
+	return nil
+}
+
+// split separates the material of a lineage inside [from, to) from the rest.
+func split(l lineage, from, to int) (inside, outside lineage) {
+	for _, s := range l {
+		if s.to <= from || s.from >= to {
+			outside = append(outside, s)
+			continue
+		}
+		if s.from < from {
+			outside = append(outside, segment{from: s.from, to: from, samples: s.samples})
+		}
+		inside = append(inside, segment{from: maxInt(s.from, from), to: minInt(s.to, to), samples: s.samples})
+		if s.to > to {
+			outside = append(outside, segment{from: to, to: s.to, samples: s.samples})
+		}
+	}
+	return
+}
+
+// removeLineages removes lineages i and j.
+func removeLineages(lineages []lineage, i, j int) []lineage {
+	remains := []lineage{}
+	for k, l := range lineages {
+		if k != i && k != j {
+			remains = append(remains, l)
+		}
+	}
+	return remains
+}
+
+var nucleotides = []byte("ATGC")
+
+// mutateSequences applies mutations, from the oldest one,
+// to a random ancestral sequence.
+// At a site, samples of an older mutation include or exclude
+// those of a younger one, so they share a base before it.
+func mutateSequences(mutations []mutation, n, L int, rng *rand.Rand) [][]byte {
+	ancestor := make([]byte, L)
+	for i := range ancestor {
+		ancestor[i] = nucleotides[rng.Intn(4)]
+	}
+	seqs := make([][]byte, n)
+	for i := range seqs {
+		seqs[i] = append([]byte{}, ancestor...)
+	}
+
+	sort.Sort(byTime(mutations))
+	for _, m := range mutations {
+		var old byte
+		for i := 0; i < n; i++ {
+			if m.samples.has(i) {
+				old = seqs[i][m.site]
+				break
+			}
+		}
+		b := nucleotides[rng.Intn(4)]
+		for b == old {
+			b = nucleotides[rng.Intn(4)]
+		}
+		for i := 0; i < n; i++ {
+			if m.samples.has(i) {
+				seqs[i][m.site] = b
+			}
+		}
+	}
+	return seqs
+}
+
+// byTime sorts mutations from the oldest.
+type byTime []mutation
+
+func (m byTime) Len() int           { return len(m) }
+func (m byTime) Less(i, j int) bool { return m[i].time > m[j].time }
+func (m byTime) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
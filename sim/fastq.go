@@ -0,0 +1,93 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/mingzhi/biogo/seq"
+	"io"
+	"math/rand"
+)
+
+// ErrorProfile contains error rates at positions of reads.
+type ErrorProfile []float64
+
+// LinearErrorProfile returns error rates increasing linearly
+// from the first to the last position of reads.
+func LinearErrorProfile(readLength int, first, last float64) ErrorProfile {
+	e := make(ErrorProfile, readLength)
+	for i := range e {
+		if readLength > 1 {
+			e[i] = first + (last-first)*float64(i)/float64(readLength-1)
+		} else {
+			e[i] = first
+		}
+	}
+	return e
+}
+
+// Qualities returns the Phred+33 quality string of the profile.
+func (e ErrorProfile) Qualities() []byte {
+	qual := make([]byte, len(e))
+	for i, rate := range e {
+		qual[i] = phredQual(rate) + 33
+	}
+	return qual
+}
+
+// FastqRecord is a read in FASTQ format.
+type FastqRecord struct {
+	Name string
+	Seq  []byte
+	Qual []byte // Phred+33 qualities.
+}
+
+// Write writes the record in FASTQ format.
+func (r FastqRecord) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "@%s\n%s\n+\n%s\n", r.Name, r.Seq, r.Qual)
+	return err
+}
+
+// PairedFastq simulates a read pair from a fragment of the genome,
+// drawn from a random position and strand.
+// Read 1 is the start of the fragment, and read 2 the reverse complement of its end.
+// It returns an error if the genome is shorter than reads.
+func PairedFastq(name string, genomeSeq []byte, insertSize int, insertSD float64, profile ErrorProfile, rng *rand.Rand) (r1, r2 FastqRecord, err error) {
+	readLength := len(profile)
+	L := len(genomeSeq)
+	if L < readLength {
+		err = fmt.Errorf("sim: genome of %d bp is shorter than reads of %d bp", L, readLength)
+		return
+	}
+	insert := int(float64(insertSize) + rng.NormFloat64()*insertSD)
+	if insert < readLength {
+		insert = readLength
+	}
+	if insert > L {
+		insert = L
+	}
+	start := rng.Intn(L - insert + 1)
+	fragment := append([]byte{}, genomeSeq[start:start+insert]...)
+	if rng.Intn(2) == 1 {
+		fragment = seq.Reverse(seq.Complement(fragment))
+	}
+
+	end := seq.Reverse(seq.Complement(append([]byte{}, fragment[insert-readLength:]...)))
+	qual := profile.Qualities()
+	r1 = FastqRecord{Name: name + "/1", Seq: profileErrors(fragment[:readLength], profile, rng), Qual: qual}
+	r2 = FastqRecord{Name: name + "/2", Seq: profileErrors(end, profile, rng), Qual: qual}
+	return
+}
+
+// profileErrors copies the read, substituting bases at rates of the profile.
+func profileErrors(s []byte, profile ErrorProfile, rng *rand.Rand) []byte {
+	read := append([]byte{}, s...)
+	for i := range read {
+		if rng.Float64() < profile[i] {
+			b := nucleotides[rng.Intn(4)]
+			for b == read[i] {
+				b = nucleotides[rng.Intn(4)]
+			}
+			read[i] = b
+		}
+	}
+	return read
+}
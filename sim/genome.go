@@ -0,0 +1,224 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/mingzhi/biogo/seq"
+	"github.com/mingzhi/meta/genome"
+	"github.com/mingzhi/meta/strain"
+	"github.com/mingzhi/ncbiftp/seqrecord"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Layout of genes on simulated genomes,
+// shared by all genomes of a sample.
+type Layout struct {
+	GeneLength int // gene length in bp, a multiple of 3.
+	Spacer     int // intergenic length in bp.
+}
+
+// fourFoldPrefixes are the first two bases of
+// four-fold degenerate codons in the standard genetic code.
+var fourFoldPrefixes = map[string]bool{
+	"CT": true, "GT": true, "TC": true, "CC": true,
+	"AC": true, "GC": true, "CG": true, "GG": true,
+}
+
+// Genes lays out genes of random strands on a genome of length L.
+func (lay Layout) Genes(L int, rng *rand.Rand) (genes []genome.Gene) {
+	for from := lay.Spacer; from+lay.GeneLength <= L; from += lay.GeneLength + lay.Spacer {
+		strand := "+"
+		if rng.Intn(2) == 1 {
+			strand = "-"
+		}
+		genes = append(genes, genome.Gene{From: from + 1, To: from + lay.GeneLength, Strand: strand})
+	}
+	return
+}
+
+// Genomes creates genomes from simulated sequences,
+// with the same genes, and position profiles determined from their own codons.
+func Genomes(seqs [][]byte, genes []genome.Gene) (genomes []genome.Genome) {
+	for i, s := range seqs {
+		g := genome.Genome{
+			Accession: fmt.Sprintf("SM_%07d", i+1),
+			Replicon:  "chromosome",
+			Length:    len(s),
+			Seq:       s,
+			Genes:     genes,
+		}
+		g.PosProfile = Profile(s, genes)
+		genomes = append(genomes, g)
+	}
+	return
+}
+
+// Profile determines the position profile of a genome,
+// as strain.ProfileGenomes does with the standard genetic code.
+func Profile(genomeSeq []byte, genes []genome.Gene) genome.Profile {
+	profile := make(genome.Profile, len(genomeSeq))
+	for _, gene := range genes {
+		nucl := geneSeq(genomeSeq, gene)
+		prof := make([]byte, len(nucl))
+		for j := range nucl {
+			switch (j + 1) % 3 {
+			case 1:
+				prof[j] = genome.FirstPos
+			case 2:
+				prof[j] = genome.SecondPos
+			case 0:
+				if fourFoldPrefixes[string(nucl[j-2:j])] {
+					prof[j] = genome.FourFold
+				} else {
+					prof[j] = genome.ThirdPos
+				}
+			}
+		}
+		if gene.Strand == "-" {
+			prof = seq.Reverse(prof)
+		}
+		copy(profile[gene.From-1:], prof)
+	}
+	return profile
+}
+
+// geneSeq returns the sequence of a gene in its orientation.
+func geneSeq(genomeSeq []byte, gene genome.Gene) []byte {
+	nucl := append([]byte{}, genomeSeq[gene.From-1:gene.To]...)
+	if gene.Strand == "-" {
+		nucl = seq.Reverse(seq.Complement(nucl))
+	}
+	return nucl
+}
+
+// Alignments returns ortholog alignments of genes of the genomes,
+// in the format of ortho_aln output, which cov.GenomesCalc consumes.
+// Simulated genomes have no indels, so alignments have no gaps.
+func Alignments(genomes []genome.Genome) (alns []seqrecord.SeqRecords) {
+	if len(genomes) == 0 {
+		return
+	}
+	for i, gene := range genomes[0].Genes {
+		records := seqrecord.SeqRecords{}
+		for _, g := range genomes {
+			rec := seqrecord.SeqRecord{
+				Id:     fmt.Sprintf("%s_%d", g.Accession, i+1),
+				Name:   fmt.Sprintf("gene%d", i+1),
+				Genome: g.Accession,
+				Code:   "11",
+				Loc:    seqrecord.Loc{From: gene.From, To: gene.To, Strand: gene.Strand},
+				Nucl:   geneSeq(g.Seq, gene),
+			}
+			records = append(records, rec)
+		}
+		alns = append(alns, records)
+	}
+	return
+}
+
+// Strains wraps each genome in a strain of the species,
+// whose genome files are in the species folder of the reference base.
+func Strains(species string, genomes []genome.Genome) (strains []strain.Strain) {
+	for _, g := range genomes {
+		s := strain.Strain{
+			Name:        species + " " + g.Accession,
+			TaxId:       g.Accession,
+			ProjectId:   g.Accession,
+			Genomes:     []genome.Genome{{Accession: g.Accession, Replicon: g.Replicon, Length: g.Length}},
+			Path:        species,
+			GeneticCode: "11",
+			Species:     species,
+			Status:      "Complete",
+		}
+		strains = append(strains, s)
+	}
+	return
+}
+
+// WriteGenome writes .fna, .pos and .ptt files of the genome in dir,
+// which genome.LoadFna, genome.LoadProfile and genome.LoadGenes read.
+func WriteGenome(g genome.Genome, dir string) {
+	base := filepath.Join(dir, g.RefAcc())
+	writeFasta(base+".fna", g.RefAcc(), g.Seq)
+	writeFile(base+".pos", g.PosProfile)
+	writePtt(base+".ptt", g)
+}
+
+// WriteAlignments writes ortholog alignments to a json file,
+// such as <prefix>_orthologs_aligned.json.
+func WriteAlignments(fileName string, alns []seqrecord.SeqRecords) {
+	w, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(alns); err != nil {
+		panic(err)
+	}
+}
+
+// WriteStrains writes strains to a json file, such as reference_strains.json.
+func WriteStrains(fileName string, strains []strain.Strain) {
+	w, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(strains); err != nil {
+		panic(err)
+	}
+}
+
+func writeFasta(fileName, name string, s []byte) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	fmt.Fprintf(w, ">%s\n", name)
+	for i := 0; i < len(s); i += 70 {
+		w.Write(s[i:minInt(i+70, len(s))])
+		w.WriteByte('\n')
+	}
+}
+
+func writeFile(fileName string, data []byte) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		panic(err)
+	}
+}
+
+// writePtt writes genes in the NCBI protein table format.
+func writePtt(fileName string, g genome.Genome) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	fmt.Fprintf(w, "%s, simulated genome - 1..%d\n", g.Accession, len(g.Seq))
+	fmt.Fprintf(w, "%d proteins\n", len(g.Genes))
+	fmt.Fprintf(w, "Location\tStrand\tLength\tPID\tGene\tSynonym\tCode\tCOG\tProduct\n")
+	for i, gene := range g.Genes {
+		fmt.Fprintf(w, "%d..%d\t%s\t%d\t%d\tgene%d\t%s_%d\t-\t-\tsimulated protein\n",
+			gene.From, gene.To, gene.Strand, (gene.To-gene.From+1)/3-1, i+1, i+1, g.Accession, i+1)
+	}
+}
@@ -0,0 +1,121 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/biogo/hts/sam"
+	"github.com/mingzhi/meta/genome"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// ReadParams of simulated paired-end reads.
+type ReadParams struct {
+	Coverage   float64 // mean coverage of each genome.
+	ReadLength int     // length of each read.
+	InsertSize int     // mean fragment length.
+	InsertSD   float64 // standard deviation of fragment lengths.
+	ErrorRate  float64 // per-base sequencing error rate.
+}
+
+// PairedEndReads simulates paired-end reads from the genomes,
+// mapped to the reference at their true positions,
+// since simulated genomes have no indels.
+// Reads of the pairs in the reference are ready for reads.GetPairedEndReads,
+// and cov.ReadsVsReads.
+func PairedEndReads(genomes []genome.Genome, ref *sam.Reference, p ReadParams, rng *rand.Rand) (records []*sam.Record) {
+	qual := phredQual(p.ErrorRate)
+	cigar := []sam.CigarOp{sam.NewCigarOp(sam.CigarMatch, p.ReadLength)}
+	for _, g := range genomes {
+		L := len(g.Seq)
+		// no reads from genomes shorter than reads.
+		if L < p.ReadLength {
+			continue
+		}
+		numPairs := int(p.Coverage * float64(L) / float64(2*p.ReadLength))
+		for i := 0; i < numPairs; i++ {
+			insert := int(float64(p.InsertSize) + rng.NormFloat64()*p.InsertSD)
+			if insert < p.ReadLength {
+				insert = p.ReadLength
+			}
+			if insert > L {
+				insert = L
+			}
+			left := rng.Intn(L - insert + 1)
+			right := left + insert - p.ReadLength
+
+			name := fmt.Sprintf("%s_%d", g.RefAcc(), i+1)
+			seq1 := sequencingErrors(g.Seq[left:left+p.ReadLength], p.ErrorRate, rng)
+			seq2 := sequencingErrors(g.Seq[right:right+p.ReadLength], p.ErrorRate, rng)
+			r1, err := sam.NewRecord(name, ref, ref, left, right, insert, 60, cigar, seq1, qualities(qual, p.ReadLength), nil)
+			if err != nil {
+				panic(err)
+			}
+			r1.Flags = sam.Paired | sam.ProperPair | sam.MateReverse | sam.Read1
+			r2, err := sam.NewRecord(name, ref, ref, right, left, -insert, 60, cigar, seq2, qualities(qual, p.ReadLength), nil)
+			if err != nil {
+				panic(err)
+			}
+			r2.Flags = sam.Paired | sam.ProperPair | sam.Reverse | sam.Read2
+			records = append(records, r1, r2)
+		}
+	}
+	return
+}
+
+// NewReference creates the SAM reference of a genome.
+func NewReference(g genome.Genome) *sam.Reference {
+	ref, err := sam.NewReference(g.Accession, "", "", len(g.Seq), nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	return ref
+}
+
+// WriteSam writes records mapped to the reference to a SAM file.
+func WriteSam(fileName string, ref *sam.Reference, records []*sam.Record) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	header, err := sam.NewHeader(nil, []*sam.Reference{ref})
+	if err != nil {
+		panic(err)
+	}
+	w, err := sam.NewWriter(f, header, sam.FlagDecimal)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// sequencingErrors copies the read, substituting bases at the error rate.
+func sequencingErrors(s []byte, rate float64, rng *rand.Rand) []byte {
+	return profileErrors(s, LinearErrorProfile(len(s), rate, rate), rng)
+}
+
+// phredQual returns the Phred quality of an error rate, up to 41.
+func phredQual(rate float64) byte {
+	if rate <= 0 {
+		return 41
+	}
+	q := -10 * math.Log10(rate)
+	if q > 41 {
+		q = 41
+	}
+	return byte(q)
+}
+
+func qualities(q byte, n int) []byte {
+	qual := make([]byte, n)
+	for i := range qual {
+		qual[i] = q
+	}
+	return qual
+}
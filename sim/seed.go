@@ -0,0 +1,19 @@
+package sim
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+// NewJobRand creates a random generator of a job,
+// whose seed is derived from the global seed and the job identity,
+// so that results do not depend on the scheduling of jobs.
+func NewJobRand(seed int64, identity ...interface{}) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprint(h, seed)
+	for _, id := range identity {
+		fmt.Fprintf(h, "/%v", id)
+	}
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
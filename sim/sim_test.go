@@ -0,0 +1,123 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSimulateKs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	p := Params{SampleSize: 10, Length: 20000, Theta: 0.05, Rho: 0.05, TractLength: 100}
+	// expected pairwise distance under Jukes-Cantor mutations.
+	expected := p.Theta / (1 + 4*p.Theta/3)
+
+	sum, count := 0.0, 0
+	for rep := 0; rep < 5; rep++ {
+		seqs := Simulate(p, rng)
+		if len(seqs) != p.SampleSize {
+			t.Fatalf("sample size: got %d, want %d\n", len(seqs), p.SampleSize)
+		}
+		for i := 0; i < len(seqs); i++ {
+			if len(seqs[i]) != p.Length {
+				t.Fatalf("genome length: got %d, want %d\n", len(seqs[i]), p.Length)
+			}
+			for j := i + 1; j < len(seqs); j++ {
+				sum += distance(seqs[i], seqs[j])
+				count++
+			}
+		}
+	}
+
+	ks := sum / float64(count)
+	if math.Abs(ks-expected)/expected > 0.2 {
+		t.Errorf("Ks: got %g, want %g\n", ks, expected)
+	}
+}
+
+func TestSimulateRecombination(t *testing.T) {
+	// gene conversion decorrelates distances between distant sites,
+	// so the variance of distances decreases with recombination.
+	p := Params{SampleSize: 2, Length: 5000, Theta: 0.05, TractLength: 100}
+	variance := func(rho float64) float64 {
+		rng := rand.New(rand.NewSource(1))
+		p.Rho = rho
+		ds := []float64{}
+		for rep := 0; rep < 100; rep++ {
+			seqs := Simulate(p, rng)
+			ds = append(ds, distance(seqs[0], seqs[1]))
+		}
+		mean, v := 0.0, 0.0
+		for _, d := range ds {
+			mean += d / float64(len(ds))
+		}
+		for _, d := range ds {
+			v += (d - mean) * (d - mean) / float64(len(ds)-1)
+		}
+		return v
+	}
+
+	v0, v1 := variance(0), variance(0.05)
+	if v1 >= v0/2 {
+		t.Errorf("variance of distances: got %g with recombination, %g without\n", v1, v0)
+	}
+}
+
+func TestMergeSplit(t *testing.T) {
+	n := 3
+	a, b := newSampleSet(n), newSampleSet(n)
+	a.add(0)
+	b.add(1)
+	inside, outside := split(lineage{{from: 0, to: 100, samples: a}}, 40, 60)
+	if inside.material() != 20 || outside.material() != 80 {
+		t.Fatalf("split: got %d inside and %d outside\n", inside.material(), outside.material())
+	}
+
+	merged := merge(inside, lineage{{from: 50, to: 150, samples: b}}, n)
+	if merged.material() != 110 {
+		t.Errorf("merged material: got %d, want 110\n", merged.material())
+	}
+	if s := covering(merged, 55); s == nil || s.count() != 2 {
+		t.Errorf("coalesced segment should have 2 samples\n")
+	}
+}
+
+func TestPairedFastq(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	profile := LinearErrorProfile(100, 0, 0)
+	genomeSeq := []byte(strings.Repeat("ACGT", 50))
+	r1, r2, err := PairedFastq("r", genomeSeq, 300, 30, profile, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r1.Seq) != 100 || len(r2.Seq) != 100 {
+		t.Errorf("read lengths: got %d and %d, want 100\n", len(r1.Seq), len(r2.Seq))
+	}
+
+	if _, _, err := PairedFastq("r", genomeSeq[:50], 300, 30, profile, rng); err == nil {
+		t.Errorf("Expect an error of a genome shorter than reads\n")
+	}
+}
+
+func distance(a, b []byte) float64 {
+	d := 0
+	for i := range a {
+		if a[i] != b[i] {
+			d++
+		}
+	}
+	return float64(d) / float64(len(a))
+}
+
+func TestNewJobRand(t *testing.T) {
+	a, b := NewJobRand(1, "gene", 2), NewJobRand(1, "gene", 2)
+	c := NewJobRand(1, "gene", 3)
+	x, y, z := a.Int63(), b.Int63(), c.Int63()
+	if x != y {
+		t.Errorf("same job, Expect %d, got %d\n", x, y)
+	}
+	if x == z {
+		t.Errorf("different jobs, got the same %d\n", x)
+	}
+}